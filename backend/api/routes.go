@@ -1,11 +1,14 @@
 package api
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gpxbase/backend/config"
 	"gpxbase/backend/handlers"
 	"gpxbase/backend/middleware"
+	"gpxbase/backend/storage"
 )
 
 // SetupRouter configures all the routes for the application
@@ -16,13 +19,24 @@ func SetupRouter(db *pgxpool.Pool, cfg *config.Config) *gin.Engine {
 	r.Use(middleware.RequestResponseLogger())
 	r.Use(gin.Recovery())
 
+	// Initialize the file storage shared by all handlers
+	fileStorage, err := storage.NewFileStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("ERROR: Failed to initialize %s storage: %v", cfg.Storage.Backend, err)
+	}
+	log.Printf("INFO: %s storage initialized successfully", cfg.Storage.Backend)
+
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(db, cfg.JWT.SecretKey)
 	healthHandler := handlers.NewHealthHandler(db)
-	routeHandler := handlers.NewRouteHandler(db)
-	publicRouteHandler := handlers.NewPublicRouteHandler(db)
+	routeHandler := handlers.NewRouteHandler(db, fileStorage, cfg.Upload.MaxFileSize, cfg.Cleaning)
+	publicRouteHandler := handlers.NewPublicRouteHandler(db, fileStorage)
 	spatialRouteHandler := handlers.NewSpatialRouteHandler(db)
 
+	// Delete direct and resumable uploads abandoned by their clients
+	routeHandler.StartRouteUploadCleanup(handlers.RouteUploadCleanupInterval)
+	routeHandler.StartTusUploadCleanup(handlers.TusCleanupInterval)
+
 	// API group
 	api := r.Group("/api")
 	{
@@ -56,6 +70,20 @@ func SetupRouter(db *pgxpool.Pool, cfg *config.Config) *gin.Engine {
 				routes.GET("/:id", routeHandler.GetRoute)       // Get route + download URL
 				routes.PUT("/:id", routeHandler.UpdateRoute)    // Update route metadata
 				routes.DELETE("/:id", routeHandler.DeleteRoute) // Delete route + GPX file
+
+				routes.GET("/:id/waypoints", routeHandler.GetRouteWaypoints)                  // List route waypoints
+				routes.POST("/:id/waypoints", routeHandler.CreateRouteWaypoint)               // Add a waypoint
+				routes.PUT("/:id/waypoints/:waypointId", routeHandler.UpdateRouteWaypoint)    // Update a waypoint
+				routes.DELETE("/:id/waypoints/:waypointId", routeHandler.DeleteRouteWaypoint) // Delete a waypoint
+
+				routes.POST("/uploads", routeHandler.CreateRouteUpload)                // Get a presigned URL to PUT the file to storage
+				routes.POST("/uploads/:id/complete", routeHandler.CompleteRouteUpload) // Validate the uploaded file + create route
+
+				routes.OPTIONS("/tus", routeHandler.GetTusOptions)       // Resumable uploads (tus 1.0.0): supported version and extensions
+				routes.POST("/tus", routeHandler.CreateTusUpload)        // Start a resumable upload (Upload-Length, Upload-Metadata)
+				routes.HEAD("/tus/:id", routeHandler.GetTusUploadOffset) // Bytes received so far (Upload-Offset)
+				routes.PATCH("/tus/:id", routeHandler.PatchTusUpload)    // Append a chunk; the last one creates the route (X-Route-Id)
+				routes.DELETE("/tus/:id", routeHandler.DeleteTusUpload)  // Cancel a resumable upload
 			}
 
 			// Public routes for browsing all routes
@@ -63,7 +91,13 @@ func SetupRouter(db *pgxpool.Pool, cfg *config.Config) *gin.Engine {
 			{
 				public.GET("/routes", publicRouteHandler.GetAllRoutes) // Get all routes from all users
 				public.GET("/routes/spatial", spatialRouteHandler.GetRoutesInBounds) // Get routes within map bounds
+				public.GET("/routes/nearby", spatialRouteHandler.GetNearbyRoutes) // Get routes within a radius, nearest first
+				public.POST("/routes/search/geometry", spatialRouteHandler.SearchRoutesByGeometry) // Get routes along a GeoJSON line or inside an area
+				public.GET("/tiles/:z/:x/:y", spatialRouteHandler.GetRouteTile) // Route lines and start points as a vector tile ({y}.mvt)
 				public.GET("/download/routes/:id", publicRouteHandler.GeneratePublicDownloadURL) // Generate download URL for any route (public access)
+				public.GET("/routes/:id/export", publicRouteHandler.ExportRoute) // Export route as geojson, kml, kmz, tcx, csv or gpx
+				public.GET("/routes/:id/profile", publicRouteHandler.GetRouteProfile) // Elevation and speed profile of a route
+				public.GET("/routes/:id/splits", publicRouteHandler.GetRouteSplits) // Splits at a distance (?distance=1km) and laps
 			}
 
 			// Download routes (authenticated but can download any route)
@@ -72,6 +106,13 @@ func SetupRouter(db *pgxpool.Pool, cfg *config.Config) *gin.Engine {
 			{
 				download.GET("/routes/:id", publicRouteHandler.GenerateDownloadURL) // Generate download URL for any route
 			}
+
+			// Signed downloads of backends without their own file hosting (local, memory)
+			if signedStorage, ok := fileStorage.(storage.SignedFileStorage); ok {
+				fileHandler := handlers.NewFileHandler(signedStorage, cfg.Upload.MaxFileSize)
+				v1.GET("/files/*key", fileHandler.ServeFile)   // Download a file with a signed URL (?expires=&filename=&signature=)
+				v1.PUT("/files/*key", fileHandler.ReceiveFile) // Upload a file with a signed URL (?expires=&signature=)
+			}
 		}
 	}
 
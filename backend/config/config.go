@@ -2,12 +2,17 @@ package config
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"gpxbase/backend/storage"
+	"gpxbase/backend/utils"
 )
 
 type Config struct {
@@ -15,6 +20,9 @@ type Config struct {
 	Env      string
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Upload   UploadConfig
+	Cleaning utils.CleaningOptions
+	Storage  storage.Options
 }
 
 type DatabaseConfig struct {
@@ -34,6 +42,10 @@ type JWTConfig struct {
 	SecretKey []byte
 }
 
+type UploadConfig struct {
+	MaxFileSize int64 // in bytes
+}
+
 func LoadConfig() *Config {
 	jwtSecret := getEnv("JWT_SECRET", "your-256-bit-secret")
 	if len(jwtSecret) < 32 {
@@ -58,9 +70,62 @@ func LoadConfig() *Config {
 		JWT: JWTConfig{
 			SecretKey: []byte(jwtSecret),
 		},
+		Upload: UploadConfig{
+			MaxFileSize: int64(getEnvAsInt("MAX_UPLOAD_SIZE_MB", 500)) << 20,
+		},
+		Cleaning: loadCleaningOptions(),
+		Storage:  loadStorageOptions(jwtSecret),
+	}
+}
+
+// loadCleaningOptions reads the GPS cleaning settings, falling back to the defaults for unset values
+func loadCleaningOptions() utils.CleaningOptions {
+	defaults := utils.DefaultCleaningOptions()
+	return utils.CleaningOptions{
+		Enabled:                getEnvAsBool("GPX_CLEANING_ENABLED", defaults.Enabled),
+		DropDuplicates:         getEnvAsBool("GPX_CLEANING_DROP_DUPLICATES", defaults.DropDuplicates),
+		MaxSpeedKmh:            getEnvAsFloat("GPX_CLEANING_MAX_SPEED_KMH", defaults.MaxSpeedKmh),
+		MaxConsecutiveOutliers: getEnvAsInt("GPX_CLEANING_MAX_CONSECUTIVE_OUTLIERS", defaults.MaxConsecutiveOutliers),
+		MaxUntimedJumpMeters:   getEnvAsFloat("GPX_CLEANING_MAX_UNTIMED_JUMP_M", defaults.MaxUntimedJumpMeters),
+		ElevationSpikeMeters:   getEnvAsFloat("GPX_CLEANING_ELEVATION_SPIKE_M", defaults.ElevationSpikeMeters),
+		MaxElevationGrade:      getEnvAsFloat("GPX_CLEANING_MAX_ELEVATION_GRADE", defaults.MaxElevationGrade),
+		DropInvalidTimestamps:  getEnvAsBool("GPX_CLEANING_DROP_INVALID_TIMESTAMPS", defaults.DropInvalidTimestamps),
 	}
 }
 
+// loadStorageOptions reads the storage backend settings. Download URLs of the local and memory backends
+// are signed with STORAGE_SIGNING_KEY, or with a key derived from the JWT secret when it is not set.
+func loadStorageOptions(jwtSecret string) storage.Options {
+	return storage.Options{
+		Backend: getEnv("STORAGE_BACKEND", storage.BackendR2),
+		S3: storage.S3Options{
+			Endpoint:             getEnv("S3_ENDPOINT", ""),
+			Region:               getEnv("S3_REGION", "us-east-1"),
+			Bucket:               getEnv("S3_BUCKET_NAME", ""),
+			AccessKeyID:          getEnv("S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey:      getEnv("S3_SECRET_ACCESS_KEY", ""),
+			UsePathStyle:         getEnvAsBool("S3_USE_PATH_STYLE", false),
+			ServerSideEncryption: getEnv("S3_SSE", ""),
+			SSEKMSKeyID:          getEnv("S3_SSE_KMS_KEY_ID", ""),
+			StorageClass:         getEnv("S3_STORAGE_CLASS", ""),
+		},
+		LocalDir:   getEnv("STORAGE_LOCAL_DIR", "./data/files"),
+		SigningKey: storageSigningKey(jwtSecret),
+		PublicURL:  getEnv("STORAGE_PUBLIC_URL", "http://localhost:"+getEnv("PORT", "8000")+"/api/v1/files"),
+	}
+}
+
+// storageSigningKey returns the dedicated URL signing key, or HMAC(jwtSecret, "storage-url-signing") so that
+// storage URLs and JWTs are never signed with the same key
+func storageSigningKey(jwtSecret string) []byte {
+	if key := os.Getenv("STORAGE_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("storage-url-signing"))
+	return mac.Sum(nil)
+}
+
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
 		c.User, c.Password, c.Host, c.Port, c.DBName, c.SSLMode)
@@ -106,4 +171,28 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
+}
+
+func getEnvAsInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
 } 
\ No newline at end of file
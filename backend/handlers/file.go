@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/storage"
+)
+
+// FileHandler serves the signed download and upload URLs of storage backends without their own file hosting
+type FileHandler struct {
+	storage       storage.SignedFileStorage
+	maxUploadSize int64
+}
+
+func NewFileHandler(fileStorage storage.SignedFileStorage, maxUploadSize int64) *FileHandler {
+	return &FileHandler{
+		storage:       fileStorage,
+		maxUploadSize: maxUploadSize,
+	}
+}
+
+// ServeFile streams a stored file after checking the signature and expiry of its URL
+func (h *FileHandler) ServeFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	filename := c.Query("filename")
+
+	if err := h.storage.VerifyURL(http.MethodGet, key, c.Query("expires"), filename, c.Query("signature")); err != nil {
+		log.Printf("WARN: Rejected file download %s: %v", key, err)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Invalid or expired download URL",
+		})
+		return
+	}
+
+	file, contentType, size, err := h.storage.OpenFile(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrFileNotFound) {
+			log.Printf("WARN: File not found for download: %s", key)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "File not found",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to open file %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read file",
+		})
+		return
+	}
+	defer file.Close()
+
+	headers := map[string]string{"Cache-Control": "private, no-store"}
+	if filename != "" {
+		headers["Content-Disposition"] = fmt.Sprintf(`attachment; filename="%s"`, filename)
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, file, headers)
+
+	log.Printf("INFO: Served file %s (%d bytes)", key, size)
+}
+
+// ReceiveFile stores the body of a PUT to a signed upload URL
+func (h *FileHandler) ReceiveFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	if err := h.storage.VerifyURL(http.MethodPut, key, c.Query("expires"), "", c.Query("signature")); err != nil {
+		log.Printf("WARN: Rejected file upload %s: %v", key, err)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Invalid or expired upload URL",
+		})
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize)
+	if err := h.storage.UploadFile(key, c.Request.Body, contentType); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Printf("ERROR: Upload to %s exceeds the limit of %d bytes", key, h.maxUploadSize)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("File exceeds the maximum upload size of %d MB", h.maxUploadSize>>20),
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to store upload %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store file",
+		})
+		return
+	}
+
+	log.Printf("INFO: Received file %s", key)
+	c.Status(http.StatusOK)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,24 +23,51 @@ const (
 	PublicDownloadURLExpirationMinutes = 1
 )
 
-type PublicRouteHandler struct {
-	db      *pgxpool.Pool
-	storage storage.FileStorage
+// effortFilters maps numeric query parameters to route columns for filtering by effort and steepness
+var effortFilters = []struct {
+	Param    string
+	Column   string
+	Operator string
+}{
+	{"min_avg_heart_rate", "avg_heart_rate", ">="},
+	{"max_avg_heart_rate", "avg_heart_rate", "<="},
+	{"min_avg_power", "avg_power", ">="},
+	{"max_avg_power", "avg_power", "<="},
+	{"min_normalized_power", "normalized_power", ">="},
+	{"max_normalized_power", "normalized_power", "<="},
+	{"min_sustained_grade", "max_sustained_grade", ">="},
+	{"max_sustained_grade", "max_sustained_grade", "<="},
 }
 
-func NewPublicRouteHandler(db *pgxpool.Pool) *PublicRouteHandler {
-	// Initialize R2 storage
-	r2Storage, err := storage.NewR2Storage()
-	if err != nil {
-		log.Printf("ERROR: Failed to initialize R2 storage: %v", err)
-		log.Fatal("Failed to initialize R2 storage")
+// effortFilterSQL builds the conditions for the effort filter query parameters, with placeholders numbered
+// from firstArg, and the values to bind to them. It fails on values that are not finite numbers.
+func effortFilterSQL(c *gin.Context, firstArg int) (string, []interface{}, error) {
+	filterSQL := ""
+	filterArgs := []interface{}{}
+	for _, filter := range effortFilters {
+		valueStr := c.Query(filter.Param)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return "", nil, fmt.Errorf("Invalid %s parameter: must be a valid number", filter.Param)
+		}
+		filterSQL += fmt.Sprintf(" AND r.%s %s $%d", filter.Column, filter.Operator, firstArg+len(filterArgs))
+		filterArgs = append(filterArgs, value)
 	}
+	return filterSQL, filterArgs, nil
+}
 
-	log.Printf("INFO: R2 storage initialized successfully for PublicRouteHandler")
+type PublicRouteHandler struct {
+	db      *pgxpool.Pool
+	storage storage.FileStorage
+}
 
+func NewPublicRouteHandler(db *pgxpool.Pool, fileStorage storage.FileStorage) *PublicRouteHandler {
 	return &PublicRouteHandler{
 		db:      db,
-		storage: r2Storage,
+		storage: fileStorage,
 	}
 }
 
@@ -53,14 +81,27 @@ func (h *PublicRouteHandler) GetAllRoutes(c *gin.Context) {
 	difficulty := c.Query("difficulty")
 	search := c.Query("search")
 
+	// Level of detail of the returned paths; without a location the zoom is taken at the equator
+	level, err := validateAndGetSimplificationLevel(c, 0)
+	if err != nil {
+		log.Printf("ERROR: Invalid simplification parameters: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	query := `
 		SELECT r.id, r.user_id, r.name, r.difficulty, r.scenery_description, r.additional_notes,
-		       r.max_elevation_gain, r.estimated_duration,
-		       r.average_speed, r.start_time, r.end_time, r.like_count, r.save_count,
+		       r.max_elevation_gain, r.total_ascent, r.total_descent, r.min_elevation, r.max_elevation,
+		       r.estimated_duration,
+		       r.average_speed, r.moving_time_seconds, r.stopped_time_seconds, r.elapsed_time_seconds,
+		       r.moving_average_speed, r.max_speed,
+		       r.avg_heart_rate, r.max_heart_rate, r.avg_cadence, r.avg_power, r.normalized_power,
+		       r.avg_temperature, r.max_sensor_speed_kmh, r.max_sustained_grade,
+		       r.start_time, r.end_time, r.like_count, r.save_count,
 		       r.filename, r.file_size, r.created_at, r.updated_at,
 		       ST_AsGeoJSON(ST_Force2D(center_point)) as center_point_geojson,
-		       ST_AsGeoJSON(ST_Force2D(simplified_path)) as simplified_path_geojson,
-		       route_length_km,
+		       ST_AsGeoJSON(ST_Force2D(` + simplifiedPathColumn(level) + `)) as simplified_path_geojson,
+		       route_length_km, route_length_3d_km,
 		       ST_AsGeoJSON(ST_Force2D(bounding_box)) as bounding_box_geojson,
 		       u.id, u.email, u.name, u.created_at, u.is_active, u.email_verified, u.last_login
 		FROM routes r
@@ -86,6 +127,17 @@ func (h *PublicRouteHandler) GetAllRoutes(c *gin.Context) {
 		argIndex++
 	}
 
+	// Add effort filters (heart rate, power, sustained grade)
+	filterSQL, filterArgs, err := effortFilterSQL(c, argIndex)
+	if err != nil {
+		log.Printf("ERROR: Invalid effort filter: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query += filterSQL
+	args = append(args, filterArgs...)
+	argIndex += len(filterArgs)
+
 	// Add ordering
 	query += " ORDER BY r.created_at DESC"
 
@@ -127,12 +179,17 @@ func (h *PublicRouteHandler) GetAllRoutes(c *gin.Context) {
 		err := rows.Scan(
 			&route.ID, &route.UserID, &route.Name, &route.Difficulty,
 			&route.SceneryDescription, &route.AdditionalNotes,
-			&route.MaxElevationGain, &route.EstimatedDuration,
-			&route.AverageSpeed, &route.StartTime, &route.EndTime,
+			&route.MaxElevationGain, &route.TotalAscent, &route.TotalDescent,
+			&route.MinElevation, &route.MaxElevation, &route.EstimatedDuration,
+			&route.AverageSpeed, &route.MovingTime, &route.StoppedTime, &route.ElapsedTime,
+			&route.MovingAverageSpeed, &route.MaxSpeed,
+			&route.AvgHeartRate, &route.MaxHeartRate, &route.AvgCadence, &route.AvgPower, &route.NormalizedPower,
+			&route.AvgTemperature, &route.MaxSensorSpeed, &route.MaxSustainedGrade,
+			&route.StartTime, &route.EndTime,
 			&route.LikeCount, &route.SaveCount,
 			&route.Filename, &route.FileSize, &route.CreatedAt, &route.UpdatedAt,
 			&centerPointGeoJSON, &simplifiedPathGeoJSON,
-			&route.RouteLength, &boundingBoxGeoJSON,
+			&route.RouteLength, &route.RouteLength3D, &boundingBoxGeoJSON,
 			&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.IsActive, &user.EmailVerified, &user.LastLogin,
 		)
 		if err != nil {
@@ -177,6 +234,10 @@ func (h *PublicRouteHandler) GetAllRoutes(c *gin.Context) {
 		argIndex++
 	}
 
+	// The effort filters follow the same parameters as in the route query, so their placeholders match
+	countQuery += filterSQL
+	countArgs = append(countArgs, filterArgs...)
+
 	var totalCount int
 	err = h.db.QueryRow(ctx, countQuery, countArgs...).Scan(&totalCount)
 	if err != nil {
@@ -192,7 +253,8 @@ func (h *PublicRouteHandler) GetAllRoutes(c *gin.Context) {
 
 	log.Printf("INFO: Successfully fetched %d routes (page %d, limit %d)", len(routes), pageNum, limitNum)
 	c.JSON(http.StatusOK, gin.H{
-		"routes":      routes,
+		"routes":         routes,
+		"simplification": level,
 		"pagination": gin.H{
 			"page":        pageNum,
 			"limit":       limitNum,
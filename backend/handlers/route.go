@@ -1,17 +1,19 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gpxbase/backend/models"
 	"gpxbase/backend/services"
@@ -19,30 +21,34 @@ import (
 	"gpxbase/backend/utils"
 )
 
+const (
+	// multipartMemoryLimit is the part of an upload kept in memory before it is spooled to a temporary file
+	multipartMemoryLimit = 32 << 20
+	// multipartFormOverhead leaves room for metadata fields and multipart boundaries on top of the file size limit
+	multipartFormOverhead = 1 << 20
+)
+
 type RouteHandler struct {
-	db         *pgxpool.Pool
-	storage    storage.FileStorage
-	geoService *services.GeoService
+	db            *pgxpool.Pool
+	storage       storage.FileStorage
+	geoService    *services.GeoService
+	blobService   *services.BlobService
+	maxUploadSize int64
+	cleaning      utils.CleaningOptions
 }
 
-func NewRouteHandler(db *pgxpool.Pool) *RouteHandler {
-	// Initialize R2 storage
-	r2Storage, err := storage.NewR2Storage()
-	if err != nil {
-		log.Printf("ERROR: Failed to initialize R2 storage: %v", err)
-		log.Fatal("Failed to initialize R2 storage")
-	}
-
-	log.Printf("INFO: R2 storage initialized successfully for RouteHandler")
-
+func NewRouteHandler(db *pgxpool.Pool, fileStorage storage.FileStorage, maxUploadSize int64, cleaning utils.CleaningOptions) *RouteHandler {
 	// Initialize GeoService
 	geoService := services.NewGeoService(db)
 	log.Printf("INFO: GeoService initialized successfully for RouteHandler")
 
 	return &RouteHandler{
-		db:         db,
-		storage:    r2Storage,
-		geoService: geoService,
+		db:            db,
+		storage:       fileStorage,
+		geoService:    geoService,
+		blobService:   services.NewBlobService(db, fileStorage),
+		maxUploadSize: maxUploadSize,
+		cleaning:      cleaning,
 	}
 }
 
@@ -59,17 +65,27 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 	}
 	log.Printf("INFO: Route creation initiated by user: %s", userID.(string))
 
-	// Parse multipart form
-	err := c.Request.ParseMultipartForm(20 << 20) // 20 MB max
+	// Parse multipart form; the body is size-limited and large files are spooled to disk instead of memory
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize+multipartFormOverhead)
+	err := c.Request.ParseMultipartForm(multipartMemoryLimit)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Printf("ERROR: Upload too large for user %s: limit %d bytes", userID.(string), h.maxUploadSize)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("File exceeds the maximum upload size of %d MB", h.maxUploadSize>>20),
+			})
+			return
+		}
 		log.Printf("ERROR: Failed to parse multipart form for user %s: %v", userID.(string), err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Failed to parse form data",
 		})
 		return
 	}
+	defer c.Request.MultipartForm.RemoveAll()
 
-	// Get the GPX file from form
+	// Get the activity file from form (GPX, FIT, TCX, KML or KMZ)
 	file, header, err := c.Request.FormFile("gpx_file")
 	if err != nil {
 		log.Printf("ERROR: Failed to get GPX file from form for user %s: %v", userID.(string), err)
@@ -79,64 +95,179 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 		return
 	}
 	defer file.Close()
-	log.Printf("INFO: Processing GPX file upload: %s (size: %d bytes)", header.Filename, header.Size)
+	log.Printf("INFO: Processing activity file upload: %s (size: %d bytes)", header.Filename, header.Size)
 
-	// Validate file extension
-	filename := header.Filename
-	if !strings.HasSuffix(strings.ToLower(filename), ".gpx") {
-		log.Printf("ERROR: Invalid file extension for user %s: %s", userID.(string), filename)
+	// Parse route metadata from form
+	var routeReq models.RouteCreateRequest
+	if err := c.ShouldBind(&routeReq); err != nil {
+		log.Printf("ERROR: Failed to parse route metadata for user %s: %v", userID.(string), err)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "File must have .gpx extension",
+			"error": "Invalid route metadata: " + err.Error(),
 		})
 		return
 	}
 
-	// Read file content
-	content, err := io.ReadAll(file)
+	route := h.createRouteFromFile(c, userID.(string), file, header.Filename, header.Size, &routeReq)
+	if route == nil {
+		return
+	}
+
+	response := route.ToResponse()
+	log.Printf("INFO: Route created successfully for user %s: %s (ID: %s)", route.UserID.String(), route.Name, route.ID.String())
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Route created successfully",
+		"route":   response,
+	})
+}
+
+// trackFile is an uploaded activity file, read several times while it is detected, decoded and stored
+type trackFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// createRouteFromFile validates and analyzes an activity file, stores it and inserts the route. It writes the
+// error response and returns nil when the route cannot be created.
+func (h *RouteHandler) createRouteFromFile(c *gin.Context, userIDStr string, file trackFile, filename string, fileSize int64, routeReq *models.RouteCreateRequest) *models.Route {
+	// Identify the file by its content so the same upload does not create a second route
+	fileHash, err := services.HashFile(file)
 	if err != nil {
-		log.Printf("ERROR: Failed to read file content for user %s, file %s: %v", userID.(string), filename, err)
+		log.Printf("ERROR: Failed to hash uploaded file %s: %v", filename, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to read file content",
 		})
-		return
+		return nil
+	}
+	ctx := context.Background()
+	var existingRouteID uuid.UUID
+	err = h.db.QueryRow(ctx, `SELECT id FROM routes WHERE user_id = $1 AND file_sha256 = $2`, userIDStr, fileHash).Scan(&existingRouteID)
+	if err == nil {
+		log.Printf("WARN: User %s uploaded file %s again, already stored as route %s", userIDStr, filename, existingRouteID.String())
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "This file was already uploaded",
+			"route_id": existingRouteID,
+		})
+		return nil
+	} else if err.Error() != "no rows in result set" {
+		log.Printf("ERROR: Failed to check for duplicate upload of %s by user %s: %v", filename, userIDStr, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check for duplicate routes",
+		})
+		return nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("ERROR: Failed to rewind uploaded file %s: %v", filename, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read file content",
+		})
+		return nil
 	}
 
-	// Basic GPX content validation
-	contentStr := string(content)
-	if !strings.Contains(contentStr, "<gpx") || !strings.Contains(contentStr, "</gpx>") {
-		log.Printf("ERROR: Invalid GPX file format for user %s, file %s: missing GPX tags", userID.(string), filename)
+	// Detect the file format from its content rather than trusting the extension
+	format, err := utils.DetectTrackFormat(file)
+	if err != nil {
+		log.Printf("ERROR: Unsupported file format for user %s, file %s: %v", userIDStr, filename, err)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid GPX file format",
+			"error": err.Error(),
 		})
-		return
+		return nil
 	}
+	log.Printf("INFO: Detected %s content in file %s", format, filename)
+
+	// Other formats are decoded and converted to GPX so they follow the same processing path
+	var gpxContent io.ReadSeeker = file
+	gpxHash, gpxSize := fileHash, fileSize
+	if format != utils.TrackFormatGPX {
+		decoded, err := utils.DecodeTrackFile(format, file, fileSize)
+		if err != nil {
+			log.Printf("ERROR: Invalid %s file format for user %s, file %s: %v", format, userIDStr, filename, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid %s file format: %v", strings.ToUpper(string(format)), err),
+			})
+			return nil
+		}
 
-	log.Printf("INFO: Successfully validated GPX file: %s", filename)
+		// The generated GPX is spooled to disk like the upload itself, it can be larger than the source file
+		generated, err := os.CreateTemp("", "route-gpx-*")
+		if err != nil {
+			log.Printf("ERROR: Failed to create temporary file for GPX generated from %s: %v", filename, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to convert " + strings.ToUpper(string(format)) + " file",
+			})
+			return nil
+		}
+		defer os.Remove(generated.Name())
+		defer generated.Close()
 
-	// Parse route metadata from form
-	var routeReq models.RouteCreateRequest
-	if err := c.ShouldBind(&routeReq); err != nil {
-		log.Printf("ERROR: Failed to parse route metadata for user %s: %v", userID.(string), err)
+		if err := utils.EncodeGPX(generated, decoded); err != nil {
+			log.Printf("ERROR: Failed to generate GPX from %s file %s: %v", format, filename, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to convert " + strings.ToUpper(string(format)) + " file",
+			})
+			return nil
+		}
+		gpxContent = generated
+		if gpxSize, err = generated.Seek(0, io.SeekCurrent); err != nil {
+			log.Printf("ERROR: Failed to size GPX generated from %s: %v", filename, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to convert " + strings.ToUpper(string(format)) + " file",
+			})
+			return nil
+		}
+		if gpxHash, err = services.HashFile(gpxContent); err != nil {
+			log.Printf("ERROR: Failed to hash GPX generated from %s: %v", filename, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to convert " + strings.ToUpper(string(format)) + " file",
+			})
+			return nil
+		}
+		log.Printf("INFO: Converted %s file %s to GPX (%d bytes)", format, filename, gpxSize)
+	}
+
+	// Validate, clean and analyze GPX content in a single streaming pass (stats, geometry, validators)
+	analysis, err := utils.AnalyzeGPXStream(gpxContent, h.cleaning)
+	if err != nil {
+		log.Printf("ERROR: Invalid GPX file format for user %s, file %s: %v", userIDStr, filename, err)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid route metadata: " + err.Error(),
+			"error": "Invalid GPX file format: " + err.Error(),
 		})
-		return
+		return nil
+	}
+
+	log.Printf("INFO: Successfully validated GPX file: %s (%d points)", filename, analysis.PointCount)
+	if report := analysis.Cleaning; report != nil && report.Raw.PointCount != report.Cleaned.PointCount {
+		log.Printf("INFO: Cleaning removed %d of %d track points from %s", report.Raw.PointCount-report.Cleaned.PointCount, report.Raw.PointCount, filename)
 	}
 
-	// Generate unique route ID and R2 object key
+	// Files are stored under keys derived from their content, identical files of different users share one object
 	routeID := uuid.New()
-	userIDStr := userID.(string)
-	objectKey := storage.GenerateObjectKey(userIDStr, routeID.String(), filename)
-	log.Printf("INFO: Uploading GPX file to R2 with key: %s", objectKey)
-
-	// Upload file to R2
-	fileReader := bytes.NewReader(content)
-	if err := h.storage.UploadFile(objectKey, fileReader, "application/gpx+xml"); err != nil {
-		log.Printf("ERROR: Failed to upload file to R2 %s: %v", objectKey, err)
+	objectKey := storage.GenerateBlobKey(gpxHash, "."+string(utils.TrackFormatGPX))
+
+	// Keep the original upload next to the generated GPX
+	var sourceObjectKey *string
+	if format != utils.TrackFormatGPX {
+		sourceKey := storage.GenerateBlobKey(fileHash, "."+string(format))
+		sourceObjectKey = &sourceKey
+
+		log.Printf("INFO: Storing original %s file with key: %s", format, sourceKey)
+		if err := h.blobService.AcquireBlob(ctx, sourceKey, file, fileSize, format.ContentType()); err != nil {
+			log.Printf("ERROR: Failed to store file %s: %v", sourceKey, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to upload file to storage",
+			})
+			return nil
+		}
+	}
+
+	log.Printf("INFO: Storing GPX file with key: %s", objectKey)
+	if err := h.blobService.AcquireBlob(ctx, objectKey, gpxContent, gpxSize, utils.TrackFormatGPX.ContentType()); err != nil {
+		log.Printf("ERROR: Failed to store file %s: %v", objectKey, err)
+		h.releaseRouteObject(sourceObjectKey)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to upload file to storage",
 		})
-		return
+		return nil
 	}
 
 	// Create route record (geographical features will be calculated by PostGIS)
@@ -152,7 +283,10 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 		SaveCount:          0, // Initialize to 0
 		Filename:           filename,
 		R2ObjectKey:        objectKey,
-		FileSize:           int64(len(content)),
+		FileSize:           fileSize,
+		SourceFormat:       string(format),
+		SourceObjectKey:    sourceObjectKey,
+		FileSHA256:         &fileHash,
 		CreatedAt:          time.Now(),
 		UpdatedAt:          time.Now(),
 	}
@@ -162,36 +296,55 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 		INSERT INTO routes (
 			id, user_id, name, difficulty, scenery_description, additional_notes,
 			max_elevation_gain, estimated_duration, like_count, save_count,
-			filename, r2_object_key, file_size, created_at, updated_at
+			filename, r2_object_key, file_size, source_format, source_object_key, file_sha256,
+			created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 	`
 
-	ctx := context.Background()
 	log.Printf("INFO: Inserting route record into database: %s", routeID.String())
 	_, err = h.db.Exec(ctx, query,
 		route.ID, route.UserID, route.Name, route.Difficulty,
 		route.SceneryDescription, route.AdditionalNotes,
 		route.MaxElevationGain, nil, route.LikeCount, route.SaveCount,
 		route.Filename, route.R2ObjectKey, route.FileSize,
+		route.SourceFormat, route.SourceObjectKey, route.FileSHA256,
 		route.CreatedAt, route.UpdatedAt,
 	)
 
 	if err != nil {
 		log.Printf("ERROR: Failed to insert route record for user %s, file %s: %v", userIDStr, filename, err)
-		// Clean up the uploaded file if database insert fails
-		if removeErr := h.storage.DeleteFile(objectKey); removeErr != nil {
-			log.Printf("ERROR: Failed to cleanup file after DB error %s: %v", objectKey, removeErr)
+		// Release the stored files if database insert fails
+		h.releaseRouteObject(&objectKey)
+		h.releaseRouteObject(sourceObjectKey)
+		// A concurrent upload of the same file by the same user won the race
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_routes_user_file_sha256" {
+			response := gin.H{
+				"error": "This file was already uploaded",
+			}
+			if err := h.db.QueryRow(ctx, `SELECT id FROM routes WHERE user_id = $1 AND file_sha256 = $2`, userIDStr, fileHash).Scan(&existingRouteID); err == nil {
+				response["route_id"] = existingRouteID
+			} else {
+				log.Printf("WARN: Failed to look up the existing route for file %s of user %s: %v", filename, userIDStr, err)
+			}
+			c.JSON(http.StatusConflict, response)
+			return nil
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to save route",
 		})
-		return
+		return nil
+	}
+
+	// Store standalone waypoints independently of track processing
+	if err := h.geoService.StoreRouteWaypoints(ctx, routeID, analysis.Waypoints); err != nil {
+		log.Printf("ERROR: Failed to store waypoints for route %s: %v", routeID.String(), err)
 	}
 
 	// Step 3: Process GPX with extended features (geographical + timing)
 	log.Printf("INFO: Processing extended features (geo + timing) for route: %s", routeID.String())
-	extendedFeatures, err := h.geoService.ProcessGPXWithExtendedFeatures(ctx, routeID, content)
+	extendedFeatures, err := h.geoService.ProcessGPXAnalysis(ctx, routeID, analysis)
 	if err != nil {
 		log.Printf("ERROR: Failed to process extended features for route %s: %v", routeID.String(), err)
 		// Don't fail the entire operation, but log the error
@@ -213,12 +366,29 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 		route.ConvexHull = extendedFeatures.ConvexHull
 		route.SimplifiedPath = extendedFeatures.SimplifiedPath
 		route.RouteLength = extendedFeatures.RouteLength
+		route.RouteLength3D = extendedFeatures.RouteLength3D
 		route.BoundingBox = extendedFeatures.BoundingBox
 		route.EstimatedDuration = extendedFeatures.Duration
 		route.AverageSpeed = extendedFeatures.AverageSpeed
+		route.MovingTime = extendedFeatures.MovingTime
+		route.StoppedTime = extendedFeatures.StoppedTime
+		route.ElapsedTime = extendedFeatures.ElapsedTime
+		route.MovingAverageSpeed = extendedFeatures.MovingAverageSpeed
+		route.MaxSpeed = extendedFeatures.MaxSpeed
+		route.AvgHeartRate = extendedFeatures.AvgHeartRate
+		route.MaxHeartRate = extendedFeatures.MaxHeartRate
+		route.AvgCadence = extendedFeatures.AvgCadence
+		route.AvgPower = extendedFeatures.AvgPower
+		route.NormalizedPower = extendedFeatures.NormalizedPower
+		route.AvgTemperature = extendedFeatures.AvgTemperature
+		route.MaxSensorSpeed = extendedFeatures.MaxSensorSpeed
 		if extendedFeatures.MaxElevationGain != nil {
 			route.MaxElevationGain = *extendedFeatures.MaxElevationGain
 		}
+		route.TotalAscent = extendedFeatures.TotalAscent
+		route.TotalDescent = extendedFeatures.TotalDescent
+		route.MinElevation = extendedFeatures.MinElevation
+		route.MaxElevation = extendedFeatures.MaxElevation
 		if extendedFeatures.StartTime != nil {
 			if startTime, err := time.Parse(time.RFC3339, *extendedFeatures.StartTime); err == nil {
 				route.StartTime = &startTime
@@ -231,12 +401,7 @@ func (h *RouteHandler) CreateRoute(c *gin.Context) {
 		}
 	}
 
-	response := route.ToResponse()
-	log.Printf("INFO: Route created successfully for user %s: %s (ID: %s)", userIDStr, route.Name, routeID.String())
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Route created successfully",
-		"route":   response,
-	})
+	return &route
 }
 
 // GetUserRoutes retrieves all routes for the authenticated user
@@ -252,15 +417,28 @@ func (h *RouteHandler) GetUserRoutes(c *gin.Context) {
 	}
 	log.Printf("INFO: Fetching routes for user: %s", userID.(string))
 
+	// Level of detail of the returned paths; without a location the zoom is taken at the equator
+	level, err := validateAndGetSimplificationLevel(c, 0)
+	if err != nil {
+		log.Printf("ERROR: Invalid simplification parameters: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	query := `
 		SELECT id, user_id, name, difficulty, scenery_description, additional_notes,
-		       max_elevation_gain, estimated_duration,
-		       average_speed, start_time, end_time, like_count, save_count,
-		       filename, file_size, 
+		       max_elevation_gain, total_ascent, total_descent, min_elevation, max_elevation,
+		       estimated_duration,
+		       average_speed, moving_time_seconds, stopped_time_seconds, elapsed_time_seconds,
+		       moving_average_speed, max_speed,
+		       avg_heart_rate, max_heart_rate, avg_cadence, avg_power, normalized_power,
+		       avg_temperature, max_sensor_speed_kmh, max_sustained_grade,
+		       start_time, end_time, like_count, save_count,
+		       filename, file_size, source_format,
 		       ST_AsText(center_point) as center_point,
 		       ST_AsText(convex_hull) as convex_hull,
-		       ST_AsText(simplified_path) as simplified_path,
-		       route_length_km,
+		       ST_AsText(` + simplifiedPathColumn(level) + `) as simplified_path,
+		       route_length_km, route_length_3d_km,
 		       ST_AsText(bounding_box) as bounding_box,
 		       created_at, updated_at
 		FROM routes 
@@ -286,12 +464,17 @@ func (h *RouteHandler) GetUserRoutes(c *gin.Context) {
 		err := rows.Scan(
 			&route.ID, &route.UserID, &route.Name, &route.Difficulty,
 			&route.SceneryDescription, &route.AdditionalNotes,
-			&route.MaxElevationGain, &route.EstimatedDuration,
-			&route.AverageSpeed, &route.StartTime, &route.EndTime,
+			&route.MaxElevationGain, &route.TotalAscent, &route.TotalDescent,
+			&route.MinElevation, &route.MaxElevation, &route.EstimatedDuration,
+			&route.AverageSpeed, &route.MovingTime, &route.StoppedTime, &route.ElapsedTime,
+			&route.MovingAverageSpeed, &route.MaxSpeed,
+			&route.AvgHeartRate, &route.MaxHeartRate, &route.AvgCadence, &route.AvgPower, &route.NormalizedPower,
+			&route.AvgTemperature, &route.MaxSensorSpeed, &route.MaxSustainedGrade,
+			&route.StartTime, &route.EndTime,
 			&route.LikeCount, &route.SaveCount,
-			&route.Filename, &route.FileSize, 
+			&route.Filename, &route.FileSize, &route.SourceFormat,
 			&route.CenterPoint, &route.ConvexHull, &route.SimplifiedPath,
-			&route.RouteLength, &route.BoundingBox,
+			&route.RouteLength, &route.RouteLength3D, &route.BoundingBox,
 			&route.CreatedAt, &route.UpdatedAt,
 		)
 		if err != nil {
@@ -307,7 +490,8 @@ func (h *RouteHandler) GetUserRoutes(c *gin.Context) {
 
 	log.Printf("INFO: Successfully fetched %d routes for user %s", len(routes), userID.(string))
 	c.JSON(http.StatusOK, gin.H{
-		"routes": routes,
+		"routes":         routes,
+		"simplification": level,
 	})
 }
 
@@ -335,14 +519,21 @@ func (h *RouteHandler) GetRoute(c *gin.Context) {
 
 	query := `
 		SELECT id, user_id, name, difficulty, scenery_description, additional_notes,
-		       max_elevation_gain, estimated_duration,
-		       average_speed, start_time, end_time, like_count, save_count,
-		       filename, r2_object_key, file_size,
+		       max_elevation_gain, total_ascent, total_descent, min_elevation, max_elevation,
+		       estimated_duration,
+		       average_speed, moving_time_seconds, stopped_time_seconds, elapsed_time_seconds,
+		       moving_average_speed, max_speed,
+		       avg_heart_rate, max_heart_rate, avg_cadence, avg_power, normalized_power,
+		       avg_temperature, max_sensor_speed_kmh,
+		       max_sustained_grade,
+		       start_time, end_time, like_count, save_count,
+		       filename, r2_object_key, file_size, source_format,
 		       ST_AsText(center_point) as center_point,
 		       ST_AsText(convex_hull) as convex_hull,
 		       ST_AsText(simplified_path) as simplified_path,
-		       route_length_km,
+		       route_length_km, route_length_3d_km,
 		       ST_AsText(bounding_box) as bounding_box,
+		       segments, climbs, gradient_histogram, processing_report,
 		       created_at, updated_at
 		FROM routes 
 		WHERE id = $1 AND user_id = $2
@@ -354,12 +545,19 @@ func (h *RouteHandler) GetRoute(c *gin.Context) {
 	err := h.db.QueryRow(ctx, query, routeID, userID.(string)).Scan(
 		&route.ID, &route.UserID, &route.Name, &route.Difficulty,
 		&route.SceneryDescription, &route.AdditionalNotes,
-		&route.MaxElevationGain, &route.EstimatedDuration,
-		&route.AverageSpeed, &route.StartTime, &route.EndTime,
+		&route.MaxElevationGain, &route.TotalAscent, &route.TotalDescent,
+		&route.MinElevation, &route.MaxElevation, &route.EstimatedDuration,
+		&route.AverageSpeed, &route.MovingTime, &route.StoppedTime, &route.ElapsedTime,
+		&route.MovingAverageSpeed, &route.MaxSpeed,
+		&route.AvgHeartRate, &route.MaxHeartRate, &route.AvgCadence, &route.AvgPower, &route.NormalizedPower,
+		&route.AvgTemperature, &route.MaxSensorSpeed,
+		&route.MaxSustainedGrade,
+		&route.StartTime, &route.EndTime,
 		&route.LikeCount, &route.SaveCount,
-		&route.Filename, &route.R2ObjectKey, &route.FileSize,
+		&route.Filename, &route.R2ObjectKey, &route.FileSize, &route.SourceFormat,
 		&route.CenterPoint, &route.ConvexHull, &route.SimplifiedPath,
-		&route.RouteLength, &route.BoundingBox,
+		&route.RouteLength, &route.RouteLength3D, &route.BoundingBox,
+		&route.Segments, &route.Climbs, &route.GradientHistogram, &route.ProcessingReport,
 		&route.CreatedAt, &route.UpdatedAt,
 	)
 
@@ -378,6 +576,15 @@ func (h *RouteHandler) GetRoute(c *gin.Context) {
 		return
 	}
 
+	route.Waypoints, err = fetchRouteWaypoints(ctx, h.db, routeID)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch waypoints of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch route",
+		})
+		return
+	}
+
 	// Generate presigned URL for file access
 	log.Printf("INFO: Generating presigned URL for route file: %s", route.R2ObjectKey)
 	presignedURL, err := h.storage.GetPresignedURLWithFilename(route.R2ObjectKey, 15*time.Minute, utils.GenerateGPXFileName(route.Name, route.ID.String()))
@@ -495,7 +702,7 @@ func (h *RouteHandler) UpdateRoute(c *gin.Context) {
 	})
 }
 
-// DeleteRoute removes a route and its associated GPX file
+// DeleteRoute removes a route and its associated GPX file (and original upload when converted)
 func (h *RouteHandler) DeleteRoute(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("userID")
@@ -517,11 +724,12 @@ func (h *RouteHandler) DeleteRoute(c *gin.Context) {
 	}
 	log.Printf("INFO: Deleting route %s for user %s", routeID, userID.(string))
 
-	// First get the R2 object key to delete the file
-	getQuery := `SELECT r2_object_key FROM routes WHERE id = $1 AND user_id = $2`
+	// First get the object keys to release the files
+	getQuery := `SELECT r2_object_key, source_object_key FROM routes WHERE id = $1 AND user_id = $2`
 	var objectKey string
+	var sourceObjectKey *string
 	ctx := context.Background()
-	err := h.db.QueryRow(ctx, getQuery, routeID, userID.(string)).Scan(&objectKey)
+	err := h.db.QueryRow(ctx, getQuery, routeID, userID.(string)).Scan(&objectKey, &sourceObjectKey)
 	if err != nil {
 		if err.Error() == "no rows in result set" {
 			log.Printf("WARN: Route not found for deletion: %s for user %s", routeID, userID.(string))
@@ -558,17 +766,24 @@ func (h *RouteHandler) DeleteRoute(c *gin.Context) {
 		return
 	}
 
-	// Delete the file from R2
-	log.Printf("INFO: Deleting route file from R2: %s", objectKey)
-	if err := h.storage.DeleteFile(objectKey); err != nil {
-		// Log the error but don't fail the request as DB record is already deleted
-		log.Printf("WARN: Failed to delete file from R2 %s: %v", objectKey, err)
-	} else {
-		log.Printf("INFO: Successfully deleted file from R2: %s", objectKey)
-	}
+	// Release the files; shared objects are only deleted with their last route. Errors are logged but don't
+	// fail the request as the DB record is already deleted
+	log.Printf("INFO: Releasing route file: %s", objectKey)
+	h.releaseRouteObject(&objectKey)
+	h.releaseRouteObject(sourceObjectKey)
 
 	log.Printf("INFO: Route deleted successfully: %s for user %s", routeID, userID.(string))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Route deleted successfully",
 	})
-}
\ No newline at end of file
+}
+
+// releaseRouteObject drops a route's reference to a stored file, if any, deleting the file with its last reference
+func (h *RouteHandler) releaseRouteObject(objectKey *string) {
+	if objectKey == nil {
+		return
+	}
+	if err := h.blobService.ReleaseBlob(context.Background(), *objectKey); err != nil {
+		log.Printf("WARN: Failed to release stored file %s: %v", *objectKey, err)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/models"
+	"gpxbase/backend/services"
+)
+
+// ClusterCellPixels is the size of a clustering grid cell on screen, in pixels of a 256 px tile
+const ClusterCellPixels = 64
+
+// getRouteClusters groups all routes in the bounds into grid cells sized for the zoom level, so dense areas
+// show their true number of routes instead of the first page of them
+func (h *SpatialRouteHandler) getRouteClusters(c *gin.Context, bounds *BoundsParams, boundsSQL, filterSQL string, filterArgs []interface{}) {
+	zoomStr := c.Query("zoom")
+	if zoomStr == "" {
+		log.Printf("ERROR: Clustered routes requested without zoom")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "zoom parameter is required when cluster=true"})
+		return
+	}
+	zoom, err := strconv.ParseFloat(zoomStr, 64)
+	if err != nil || zoom < 0 || zoom > services.MaxMapZoom {
+		log.Printf("ERROR: Invalid zoom for clustered routes: %s", zoomStr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid zoom parameter: must be between 0 and %d", services.MaxMapZoom)})
+		return
+	}
+
+	// Grid cells are square in Web Mercator, where a pixel covers the same distance at every latitude
+	cellSize := services.WebMercatorMetersPerPixel / math.Pow(2, zoom) * ClusterCellPixels
+
+	query := `
+		WITH cells AS (
+			SELECT r.id, r.difficulty, r.center_point,
+			       ST_SnapToGrid(ST_Transform(r.center_point, 3857), ` + fmt.Sprintf("$%d", 5+len(filterArgs)) + `) as cell
+			FROM routes r
+			JOIN users u ON r.user_id = u.id
+			WHERE u.is_active = true
+			  AND r.center_point IS NOT NULL
+			  AND ` + boundsSQL + filterSQL + `
+		),
+		by_difficulty AS (
+			SELECT cell, difficulty, COUNT(*) as route_count,
+			       MIN(id::text) as route_id, ST_Collect(ST_Force2D(center_point)) as points
+			FROM cells
+			GROUP BY cell, difficulty
+		)
+		SELECT SUM(route_count)::int as route_count,
+		       ST_Y(ST_Centroid(ST_Collect(points))) as latitude,
+		       ST_X(ST_Centroid(ST_Collect(points))) as longitude,
+		       ST_YMin(ST_Extent(points)) as min_lat, ST_YMax(ST_Extent(points)) as max_lat,
+		       ST_XMin(ST_Extent(points)) as min_lng, ST_XMax(ST_Extent(points)) as max_lng,
+		       jsonb_object_agg(difficulty, route_count) as difficulties,
+		       CASE WHEN SUM(route_count) = 1 THEN MIN(route_id)::uuid END as route_id
+		FROM by_difficulty
+		GROUP BY cell
+		ORDER BY route_count DESC
+	`
+
+	args := []interface{}{bounds.MinLng, bounds.MinLat, bounds.MaxLng, bounds.MaxLat}
+	args = append(args, filterArgs...)
+	args = append(args, cellSize)
+
+	ctx := context.Background()
+	rows, err := h.db.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query route clusters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch route clusters",
+		})
+		return
+	}
+	defer rows.Close()
+
+	clusters := []models.RouteCluster{}
+	totalCount := 0
+	for rows.Next() {
+		var cluster models.RouteCluster
+		err := rows.Scan(
+			&cluster.Count, &cluster.Latitude, &cluster.Longitude,
+			&cluster.MinLat, &cluster.MaxLat, &cluster.MinLng, &cluster.MaxLng,
+			&cluster.Difficulties, &cluster.RouteID,
+		)
+		if err != nil {
+			log.Printf("ERROR: Failed to scan route cluster: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to scan route clusters",
+			})
+			return
+		}
+		totalCount += cluster.Count
+		clusters = append(clusters, cluster)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Failed to read route clusters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch route clusters",
+		})
+		return
+	}
+
+	log.Printf("INFO: Grouped %d routes into %d clusters at zoom %g", totalCount, len(clusters), zoom)
+
+	c.JSON(http.StatusOK, gin.H{
+		"clusters":    clusters,
+		"total_count": totalCount,
+		"zoom":        zoom,
+		"bounds": gin.H{
+			"min_lat": bounds.MinLat,
+			"max_lat": bounds.MaxLat,
+			"min_lng": bounds.MinLng,
+			"max_lng": bounds.MaxLng,
+		},
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/utils"
+)
+
+// ExportRoute builds a route in the requested format from its stored geometry, waypoints and metadata
+// (public access, no authentication required). Supported formats: geojson, kml, kmz, tcx, csv, gpx.
+func (h *PublicRouteHandler) ExportRoute(c *gin.Context) {
+	routeID := c.Param("id")
+	if routeID == "" {
+		log.Printf("ERROR: ExportRoute - Route ID is required")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Route ID is required",
+		})
+		return
+	}
+
+	format, err := utils.ParseExportFormat(c.DefaultQuery("format", string(utils.ExportFormatGPX)))
+	if err != nil {
+		log.Printf("ERROR: ExportRoute - Invalid export format for route %s: %v", routeID, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	log.Printf("INFO: Exporting route %s as %s", routeID, format)
+
+	query := `
+		SELECT r.id, r.name, r.difficulty,
+		       COALESCE(r.scenery_description, '') as scenery_description,
+		       r.route_length_km, r.total_ascent, r.total_descent,
+		       r.start_time, r.end_time,
+		       ST_AsGeoJSON(r.original_geometry) as original_geometry, r.point_times,
+		       u.name as creator_name
+		FROM routes r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.id = $1 AND u.is_active = true
+	`
+
+	var route utils.RouteExport
+	var geometry *string
+
+	ctx := context.Background()
+	err = h.db.QueryRow(ctx, query, routeID).Scan(
+		&route.ID, &route.Name, &route.Difficulty, &route.Description,
+		&route.RouteLength, &route.TotalAscent, &route.TotalDescent,
+		&route.StartTime, &route.EndTime,
+		&geometry, &route.PointTimes, &route.CreatorName,
+	)
+
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			log.Printf("WARN: Route not found for export: %s", routeID)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Route not found",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to fetch route for export %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch route",
+		})
+		return
+	}
+
+	if geometry == nil {
+		log.Printf("WARN: Route %s has no stored geometry to export", routeID)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route geometry is not available",
+		})
+		return
+	}
+
+	route.Lines, err = utils.ParseExportGeometry(*geometry)
+	if err != nil {
+		log.Printf("ERROR: Failed to read geometry of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export route",
+		})
+		return
+	}
+	waypoints, err := fetchRouteWaypoints(ctx, h.db, routeID)
+	if err != nil {
+		// Waypoints are optional, export the route line without them
+		log.Printf("WARN: Failed to read waypoints of route %s: %v", routeID, err)
+	}
+	route.Waypoints = exportWaypoints(waypoints)
+
+	var buf bytes.Buffer
+	if err := utils.WriteRouteExport(&buf, &route, format); err != nil {
+		log.Printf("ERROR: Failed to export route %s as %s: %v", routeID, format, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export route",
+		})
+		return
+	}
+
+	filename := utils.GenerateRouteFileName(route.Name, route.ID, string(format))
+	log.Printf("INFO: Route %s exported as %s (%d bytes)", routeID, format, buf.Len())
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
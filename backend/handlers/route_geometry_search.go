@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/models"
+)
+
+const (
+	// MaxGeometrySearchBodySize limits the size of a geometry search request, in bytes
+	MaxGeometrySearchBodySize = 1 << 20
+	// DefaultLineBufferMeters is the corridor width on each side of a searched line when none is requested
+	DefaultLineBufferMeters = 50.0
+	// DefaultGeometrySearchLimit is the number of routes returned when no limit is requested
+	DefaultGeometrySearchLimit = 50
+)
+
+// searchGeometryKind returns "line" or "area" for a supported GeoJSON geometry type
+func searchGeometryKind(geometry json.RawMessage) (string, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(geometry, &header); err != nil {
+		return "", fmt.Errorf("Invalid geometry: must be a GeoJSON geometry object")
+	}
+	switch header.Type {
+	case "LineString", "MultiLineString":
+		return "line", nil
+	case "Polygon", "MultiPolygon":
+		return "area", nil
+	default:
+		return "", fmt.Errorf("Invalid geometry type %q: must be LineString, MultiLineString, Polygon or MultiPolygon", header.Type)
+	}
+}
+
+// SearchRoutesByGeometry retrieves routes overlapping a GeoJSON line or area. Lines are widened by
+// buffer_m meters on each side; results are ranked by the length of route inside the searched area.
+// The zoom or tolerance query parameter selects the level of detail of the returned paths.
+func (h *SpatialRouteHandler) SearchRoutesByGeometry(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxGeometrySearchBodySize)
+
+	var req models.RouteGeometrySearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("ERROR: Failed to parse geometry search request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid search request: " + err.Error(),
+		})
+		return
+	}
+
+	kind, err := searchGeometryKind(req.Geometry)
+	if err != nil {
+		log.Printf("ERROR: Invalid search geometry: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Areas are searched as given; lines are searched within a corridor around them
+	buffer := 0.0
+	if req.BufferMeters != nil {
+		buffer = *req.BufferMeters
+	} else if kind == "line" {
+		buffer = DefaultLineBufferMeters
+	}
+	if kind == "line" && buffer <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "buffer_m must be greater than 0 for line searches"})
+		return
+	}
+	match := req.Match
+	if match == "" {
+		match = string(BoundsMatchIntersects)
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultGeometrySearchLimit
+	}
+
+	// Validate the geometry in PostGIS before using it in the search
+	ctx := context.Background()
+	var valid bool
+	var reason string
+	var centerLat float64
+	err = h.db.QueryRow(ctx, `
+		SELECT ST_IsValid(g), ST_IsValidReason(g), COALESCE(ST_Y(ST_Centroid(g)), 0)
+		FROM (SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326) as g) search
+	`, string(req.Geometry)).Scan(&valid, &reason, &centerLat)
+	if err != nil {
+		// The database error names PostGIS internals, only the fact that the geometry was rejected is returned
+		log.Printf("WARN: Failed to read search geometry: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid geometry",
+		})
+		return
+	}
+	if !valid {
+		log.Printf("WARN: Invalid search geometry: %s", reason)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid geometry: " + reason,
+		})
+		return
+	}
+
+	// Level of detail of the returned paths, for the zoom at the center of the searched geometry
+	level, err := validateAndGetSimplificationLevel(c, centerLat)
+	if err != nil {
+		log.Printf("ERROR: Invalid simplification parameters: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	matchSQL := "ST_Intersects(r.original_geometry, area.geom)"
+	if match == string(BoundsMatchContains) {
+		matchSQL = "ST_CoveredBy(r.original_geometry, area.geom)"
+	}
+
+	query := `
+		WITH area AS (
+			SELECT CASE WHEN $2::double precision > 0
+			            THEN ST_Buffer(ST_SetSRID(ST_GeomFromGeoJSON($1), 4326)::geography, $2::double precision)::geometry
+			            ELSE ST_SetSRID(ST_GeomFromGeoJSON($1), 4326)
+			       END as geom
+		),
+		matches AS (
+			SELECT r.*,
+			       ST_Length(ST_CollectionExtract(ST_Intersection(ST_Force2D(r.original_geometry), area.geom), 2)::geography) / 1000.0 as shared_length_km,
+			       ST_CoveredBy(r.original_geometry, area.geom) as inside
+			FROM routes r, area
+			WHERE ` + matchSQL + `
+		)
+		SELECT r.id, r.user_id, r.name, r.difficulty, r.scenery_description, r.additional_notes,
+		       r.max_elevation_gain, r.total_ascent, r.total_descent, r.min_elevation, r.max_elevation,
+		       r.estimated_duration,
+		       r.average_speed, r.moving_time_seconds, r.stopped_time_seconds, r.elapsed_time_seconds,
+		       r.moving_average_speed, r.max_speed,
+		       r.avg_heart_rate, r.max_heart_rate, r.avg_cadence, r.avg_power, r.normalized_power,
+		       r.avg_temperature, r.max_sensor_speed_kmh, r.max_sustained_grade,
+		       r.start_time, r.end_time, r.like_count, r.save_count,
+		       r.filename, r.file_size, r.created_at, r.updated_at,
+		       ST_AsGeoJSON(ST_Force2D(r.center_point)) as center_point_geojson,
+		       ST_AsGeoJSON(ST_Force2D(r.` + simplifiedPathColumn(level) + `)) as simplified_path_geojson,
+		       r.route_length_km, r.route_length_3d_km,
+		       ST_AsGeoJSON(ST_Force2D(r.bounding_box)) as bounding_box_geojson,
+		       r.shared_length_km, r.inside,
+		       u.id, u.email, u.name, u.created_at, u.is_active, u.email_verified, u.last_login
+		FROM matches r
+		JOIN users u ON r.user_id = u.id
+		WHERE u.is_active = true
+		ORDER BY r.shared_length_km DESC, r.id
+		LIMIT $3
+	`
+
+	log.Printf("INFO: Searching routes along %s geometry (buffer %.0f m, match=%s, limit=%d)", kind, buffer, match, limit)
+
+	rows, err := h.db.Query(ctx, query, string(req.Geometry), buffer, limit)
+	if err != nil {
+		log.Printf("ERROR: Failed to query routes by geometry: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch routes",
+		})
+		return
+	}
+	defer rows.Close()
+
+	routes := []models.RouteGeometryMatchResponse{}
+	for rows.Next() {
+		var route models.Route
+		var user models.User
+		var centerPointGeoJSON *string
+		var simplifiedPathGeoJSON *string
+		var boundingBoxGeoJSON *string
+		var sharedLengthKm float64
+		var inside bool
+
+		err := rows.Scan(
+			&route.ID, &route.UserID, &route.Name, &route.Difficulty,
+			&route.SceneryDescription, &route.AdditionalNotes,
+			&route.MaxElevationGain, &route.TotalAscent, &route.TotalDescent,
+			&route.MinElevation, &route.MaxElevation, &route.EstimatedDuration,
+			&route.AverageSpeed, &route.MovingTime, &route.StoppedTime, &route.ElapsedTime,
+			&route.MovingAverageSpeed, &route.MaxSpeed,
+			&route.AvgHeartRate, &route.MaxHeartRate, &route.AvgCadence, &route.AvgPower, &route.NormalizedPower,
+			&route.AvgTemperature, &route.MaxSensorSpeed, &route.MaxSustainedGrade,
+			&route.StartTime, &route.EndTime,
+			&route.LikeCount, &route.SaveCount,
+			&route.Filename, &route.FileSize, &route.CreatedAt, &route.UpdatedAt,
+			&centerPointGeoJSON, &simplifiedPathGeoJSON,
+			&route.RouteLength, &route.RouteLength3D, &boundingBoxGeoJSON,
+			&sharedLengthKm, &inside,
+			&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.IsActive, &user.EmailVerified, &user.LastLogin,
+		)
+		if err != nil {
+			log.Printf("ERROR: Failed to scan route data: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to scan route data",
+			})
+			return
+		}
+
+		// Set GeoJSON fields
+		route.CenterPoint = centerPointGeoJSON
+		route.SimplifiedPath = simplifiedPathGeoJSON
+		route.BoundingBox = boundingBoxGeoJSON
+
+		result := models.RouteGeometryMatchResponse{
+			RouteWithUserResponse: route.ToResponseWithUser(user.ToPublicResponse()),
+			SharedLengthKm:        math.Round(sharedLengthKm*1000) / 1000,
+			Inside:                inside,
+		}
+		if route.RouteLength != nil && *route.RouteLength > 0 {
+			percent := sharedLengthKm / *route.RouteLength * 100
+			result.SharedPercent = math.Round(math.Min(percent, 100)*10) / 10
+		}
+		routes = append(routes, result)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Failed to read routes by geometry: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch routes",
+		})
+		return
+	}
+
+	log.Printf("INFO: Successfully fetched %d routes along %s geometry", len(routes), kind)
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes":         routes,
+		"simplification": level,
+		"search": gin.H{
+			"geometry_kind": kind,
+			"buffer_m":      buffer,
+			"match":         match,
+			"limit":         limit,
+		},
+	})
+}
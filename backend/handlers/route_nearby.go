@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/models"
+)
+
+const (
+	// DefaultNearbyRadiusKm and MaxNearbyRadiusKm bound the search radius of nearby route searches
+	DefaultNearbyRadiusKm = 25.0
+	MaxNearbyRadiusKm     = 500.0
+	// DefaultNearbyLimit and MaxNearbyLimit bound the number of nearby routes returned
+	DefaultNearbyLimit = 20
+	MaxNearbyLimit     = 100
+)
+
+// NearbyParams is a validated nearby route search
+type NearbyParams struct {
+	Lat      float64
+	Lng      float64
+	RadiusKm float64
+	Limit    int
+	Match    string // "start" to match start points, "path" to match any point of the route
+}
+
+func validateAndGetNearbyParameters(c *gin.Context) (*NearbyParams, error) {
+	latStr := c.Query("lat")
+	lngStr := c.Query("lng")
+	if latStr == "" {
+		return nil, fmt.Errorf("lat parameter is required")
+	}
+	if lngStr == "" {
+		return nil, fmt.Errorf("lng parameter is required")
+	}
+
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, fmt.Errorf("Invalid lat: must be a number between -90 and 90")
+	}
+	lng, err := strconv.ParseFloat(lngStr, 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return nil, fmt.Errorf("Invalid lng: must be a number between -180 and 180")
+	}
+
+	params := &NearbyParams{Lat: lat, Lng: lng, RadiusKm: DefaultNearbyRadiusKm, Limit: DefaultNearbyLimit, Match: "start"}
+	if radiusStr := c.Query("radius_km"); radiusStr != "" {
+		radius, err := strconv.ParseFloat(radiusStr, 64)
+		if err != nil || math.IsNaN(radius) || radius <= 0 || radius > MaxNearbyRadiusKm {
+			return nil, fmt.Errorf("Invalid radius_km: must be greater than 0 and at most %.0f", MaxNearbyRadiusKm)
+		}
+		params.RadiusKm = radius
+	}
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= MaxNearbyLimit {
+		params.Limit = l
+	}
+	switch match := c.DefaultQuery("match", "start"); match {
+	case "start", "path":
+		params.Match = match
+	default:
+		return nil, fmt.Errorf("Invalid match parameter: must be start or path")
+	}
+
+	return params, nil
+}
+
+// GetNearbyRoutes retrieves the routes starting (or passing, with match=path) within a radius of a point,
+// nearest first, with their distance from the point
+func (h *SpatialRouteHandler) GetNearbyRoutes(c *gin.Context) {
+	params, err := validateAndGetNearbyParameters(c)
+	if err != nil {
+		log.Printf("ERROR: Invalid nearby parameters: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	level, err := validateAndGetSimplificationLevel(c, params.Lat)
+	if err != nil {
+		log.Printf("ERROR: Invalid simplification parameters: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Both columns are indexed as geography: ST_DWithin filters by radius and <-> orders by distance (KNN)
+	target := "r.start_point"
+	if params.Match == "path" {
+		target = "r.original_geometry::geography"
+	}
+
+	// Effort filters (heart rate, power, sustained grade) shared with the route listing
+	filterSQL, filterArgs, err := effortFilterSQL(c, 4)
+	if err != nil {
+		log.Printf("ERROR: Invalid effort filter: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `
+		SELECT r.id, r.user_id, r.name, r.difficulty, r.scenery_description, r.additional_notes,
+		       r.max_elevation_gain, r.total_ascent, r.total_descent, r.min_elevation, r.max_elevation,
+		       r.estimated_duration,
+		       r.average_speed, r.moving_time_seconds, r.stopped_time_seconds, r.elapsed_time_seconds,
+		       r.moving_average_speed, r.max_speed,
+		       r.avg_heart_rate, r.max_heart_rate, r.avg_cadence, r.avg_power, r.normalized_power,
+		       r.avg_temperature, r.max_sensor_speed_kmh, r.max_sustained_grade,
+		       r.start_time, r.end_time, r.like_count, r.save_count,
+		       r.filename, r.file_size, r.created_at, r.updated_at,
+		       ST_AsGeoJSON(ST_Force2D(center_point)) as center_point_geojson,
+		       ST_AsGeoJSON(ST_Force2D(` + simplifiedPathColumn(level) + `)) as simplified_path_geojson,
+		       route_length_km, route_length_3d_km,
+		       ST_AsGeoJSON(ST_Force2D(bounding_box)) as bounding_box_geojson,
+		       ST_Distance(` + target + `, search.point) / 1000.0 as distance_km,
+		       u.id, u.email, u.name, u.created_at, u.is_active, u.email_verified, u.last_login
+		FROM routes r
+		JOIN users u ON r.user_id = u.id,
+		     (SELECT ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography as point) search
+		WHERE u.is_active = true
+		  AND ST_DWithin(` + target + `, search.point, $3)
+	` + filterSQL + `
+		ORDER BY ` + target + ` <-> search.point
+	` + fmt.Sprintf("LIMIT $%d", 4+len(filterArgs))
+
+	args := []interface{}{params.Lng, params.Lat, params.RadiusKm * 1000}
+	args = append(args, filterArgs...)
+	args = append(args, params.Limit)
+
+	log.Printf("INFO: Searching routes near %.6f, %.6f within %.1f km (match=%s, limit=%d)",
+		params.Lat, params.Lng, params.RadiusKm, params.Match, params.Limit)
+
+	ctx := context.Background()
+	rows, err := h.db.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to query nearby routes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch routes",
+		})
+		return
+	}
+	defer rows.Close()
+
+	routes := []models.NearbyRouteResponse{}
+	for rows.Next() {
+		var route models.Route
+		var user models.User
+		var centerPointGeoJSON *string
+		var simplifiedPathGeoJSON *string
+		var boundingBoxGeoJSON *string
+		var distanceKm float64
+
+		err := rows.Scan(
+			&route.ID, &route.UserID, &route.Name, &route.Difficulty,
+			&route.SceneryDescription, &route.AdditionalNotes,
+			&route.MaxElevationGain, &route.TotalAscent, &route.TotalDescent,
+			&route.MinElevation, &route.MaxElevation, &route.EstimatedDuration,
+			&route.AverageSpeed, &route.MovingTime, &route.StoppedTime, &route.ElapsedTime,
+			&route.MovingAverageSpeed, &route.MaxSpeed,
+			&route.AvgHeartRate, &route.MaxHeartRate, &route.AvgCadence, &route.AvgPower, &route.NormalizedPower,
+			&route.AvgTemperature, &route.MaxSensorSpeed, &route.MaxSustainedGrade,
+			&route.StartTime, &route.EndTime,
+			&route.LikeCount, &route.SaveCount,
+			&route.Filename, &route.FileSize, &route.CreatedAt, &route.UpdatedAt,
+			&centerPointGeoJSON, &simplifiedPathGeoJSON,
+			&route.RouteLength, &route.RouteLength3D, &boundingBoxGeoJSON,
+			&distanceKm,
+			&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.IsActive, &user.EmailVerified, &user.LastLogin,
+		)
+		if err != nil {
+			log.Printf("ERROR: Failed to scan nearby route data: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to scan route data",
+			})
+			return
+		}
+
+		// Set GeoJSON fields
+		route.CenterPoint = centerPointGeoJSON
+		route.SimplifiedPath = simplifiedPathGeoJSON
+		route.BoundingBox = boundingBoxGeoJSON
+
+		routes = append(routes, models.NearbyRouteResponse{
+			RouteWithUserResponse: route.ToResponseWithUser(user.ToPublicResponse()),
+			DistanceKm:            math.Round(distanceKm*1000) / 1000,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Failed to read nearby routes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch routes",
+		})
+		return
+	}
+
+	log.Printf("INFO: Successfully fetched %d routes near %.6f, %.6f", len(routes), params.Lat, params.Lng)
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes":         routes,
+		"simplification": level,
+		"search": gin.H{
+			"lat":       params.Lat,
+			"lng":       params.Lng,
+			"radius_km": params.RadiusKm,
+			"limit":     params.Limit,
+			"match":     params.Match,
+		},
+	})
+}
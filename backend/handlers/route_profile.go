@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/utils"
+)
+
+// GetRouteProfile returns distance-indexed elevation, grade, speed and time series of a route for drawing charts
+// (public access, no authentication required). The resolution query parameter sets the number of samples.
+func (h *PublicRouteHandler) GetRouteProfile(c *gin.Context) {
+	routeID := c.Param("id")
+	if routeID == "" {
+		log.Printf("ERROR: GetRouteProfile - Route ID is required")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Route ID is required",
+		})
+		return
+	}
+
+	resolution := utils.DefaultProfileResolution
+	if r, err := strconv.Atoi(c.Query("resolution")); err == nil && r > 0 {
+		resolution = r
+	}
+
+	log.Printf("INFO: Building profile of route %s with resolution %d", routeID, resolution)
+
+	query := `
+		SELECT ST_AsGeoJSON(r.original_geometry) as original_geometry, r.point_times
+		FROM routes r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.id = $1 AND u.is_active = true
+	`
+
+	var geometry *string
+	var pointTimes []*time.Time
+
+	ctx := context.Background()
+	err := h.db.QueryRow(ctx, query, routeID).Scan(&geometry, &pointTimes)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			log.Printf("WARN: Route not found for profile: %s", routeID)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Route not found",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to fetch route for profile %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch route",
+		})
+		return
+	}
+
+	if geometry == nil {
+		log.Printf("WARN: Route %s has no stored geometry for a profile", routeID)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route geometry is not available",
+		})
+		return
+	}
+
+	lines, err := utils.ParseExportGeometry(*geometry)
+	if err != nil {
+		log.Printf("ERROR: Failed to read geometry of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to build route profile",
+		})
+		return
+	}
+
+	profile, err := utils.BuildRouteProfile(lines, pointTimes, resolution)
+	if err != nil {
+		log.Printf("ERROR: Failed to build profile of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to build route profile",
+		})
+		return
+	}
+	if pointTimes != nil && !profile.HasTime {
+		log.Printf("WARN: Point times of route %s do not match its geometry (%d times, %d points)", routeID, len(pointTimes), profile.PointCount)
+	}
+
+	log.Printf("INFO: Built profile of route %s with %d samples from %d points", routeID, profile.Resolution, profile.PointCount)
+	c.JSON(http.StatusOK, gin.H{
+		"route_id": routeID,
+		"profile":  profile,
+	})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/utils"
+)
+
+// GetRouteSplits returns splits at a fixed distance (query parameter distance, e.g. 1km, 1mi or 5km) and one lap
+// per track segment, with time, pace, elevation change and average heart rate when the route has that data
+// (public access, no authentication required)
+func (h *PublicRouteHandler) GetRouteSplits(c *gin.Context) {
+	routeID := c.Param("id")
+	if routeID == "" {
+		log.Printf("ERROR: GetRouteSplits - Route ID is required")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Route ID is required",
+		})
+		return
+	}
+
+	splitMeters, err := utils.ParseSplitDistance(c.DefaultQuery("distance", utils.DefaultSplitDistance))
+	if err != nil {
+		log.Printf("ERROR: GetRouteSplits - Invalid split distance for route %s: %v", routeID, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	log.Printf("INFO: Calculating %.0f m splits of route %s", splitMeters, routeID)
+
+	query := `
+		SELECT ST_AsGeoJSON(r.original_geometry) as original_geometry, r.point_times, r.point_heart_rates
+		FROM routes r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.id = $1 AND u.is_active = true
+	`
+
+	var geometry *string
+	var pointTimes []*time.Time
+	var heartRates []*float64
+
+	ctx := context.Background()
+	err = h.db.QueryRow(ctx, query, routeID).Scan(&geometry, &pointTimes, &heartRates)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			log.Printf("WARN: Route not found for splits: %s", routeID)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Route not found",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to fetch route %s for splits: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch route",
+		})
+		return
+	}
+
+	if geometry == nil {
+		log.Printf("WARN: Route %s has no stored geometry for splits", routeID)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route geometry is not available",
+		})
+		return
+	}
+
+	lines, err := utils.ParseExportGeometry(*geometry)
+	if err != nil {
+		log.Printf("ERROR: Failed to read geometry of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate splits",
+		})
+		return
+	}
+
+	splits, err := utils.CalculateRouteSplits(lines, pointTimes, heartRates, splitMeters)
+	if err != nil {
+		log.Printf("ERROR: Failed to calculate splits of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate splits",
+		})
+		return
+	}
+
+	log.Printf("INFO: Calculated %d splits and %d laps for route %s", len(splits.Splits), len(splits.Laps), routeID)
+	c.JSON(http.StatusOK, gin.H{
+		"route_id": routeID,
+		"splits":   splits,
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gpxbase/backend/services"
+)
+
+const (
+	// MaxTileZoom is the deepest zoom level served as vector tiles
+	MaxTileZoom = 22
+	// TileExtent is the size of the tile coordinate space and TileBuffer the margin kept around it
+	TileExtent = 4096
+	TileBuffer = 64
+	// TileSizePixels is the display size of a tile, used to pick the level of detail of the route paths
+	TileSizePixels = 512
+	// TileCacheMaxAge is how long browsers and proxies may reuse a tile, in seconds
+	TileCacheMaxAge = 300
+	// MVTContentType is the media type of Mapbox Vector Tiles
+	MVTContentType = "application/vnd.mapbox-vector-tile"
+)
+
+// parseTileCoordinates reads z, x and y from the path, where y carries the .mvt extension
+func parseTileCoordinates(c *gin.Context) (int, int, int, error) {
+	z, err := strconv.Atoi(c.Param("z"))
+	if err != nil || z < 0 || z > MaxTileZoom {
+		return 0, 0, 0, fmt.Errorf("Invalid zoom: must be between 0 and %d", MaxTileZoom)
+	}
+
+	yStr, found := strings.CutSuffix(c.Param("y"), ".mvt")
+	if !found {
+		return 0, 0, 0, fmt.Errorf("Invalid tile: only .mvt tiles are supported")
+	}
+
+	n := 1 << z
+	x, err := strconv.Atoi(c.Param("x"))
+	if err != nil || x < 0 || x >= n {
+		return 0, 0, 0, fmt.Errorf("Invalid x: must be between 0 and %d at zoom %d", n-1, z)
+	}
+	y, err := strconv.Atoi(yStr)
+	if err != nil || y < 0 || y >= n {
+		return 0, 0, 0, fmt.Errorf("Invalid y: must be between 0 and %d at zoom %d", n-1, z)
+	}
+
+	return z, x, y, nil
+}
+
+// tileCenterLatitude returns the latitude of the center of a web map tile
+func tileCenterLatitude(z, y int) float64 {
+	n := math.Pow(2, float64(z))
+	return math.Atan(math.Sinh(math.Pi*(1-2*(float64(y)+0.5)/n))) * 180 / math.Pi
+}
+
+// GetRouteTile serves the routes of a web map tile as a Mapbox Vector Tile with two layers:
+// "routes" holds the paths and "route_starts" the start points, both with id, name, difficulty and length_km
+func (h *SpatialRouteHandler) GetRouteTile(c *gin.Context) {
+	z, x, y, err := parseTileCoordinates(c)
+	if err != nil {
+		log.Printf("ERROR: Invalid tile request %s: %v", c.Request.URL.Path, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Use the path whose tolerance matches the size of a tile pixel at this zoom
+	metersPerPixel := services.MetersPerPixel(float64(z), tileCenterLatitude(z, y)) * 256 / TileSizePixels
+	level := services.SelectSimplificationLevel(metersPerPixel)
+
+	query := `
+		WITH bounds AS (
+			SELECT ST_TileEnvelope($1, $2, $3) as geom,
+			       ST_Transform(ST_TileEnvelope($1, $2, $3), 4326) as geom_4326
+		),
+		tile_routes AS (
+			SELECT r.id::text as id, r.name, r.difficulty,
+			       ROUND(r.route_length_km::numeric, 2)::double precision as length_km,
+			       r.` + level.Column + ` as path,
+			       ST_PointN(ST_GeometryN(r.original_geometry, 1), 1) as start_point
+			FROM routes r
+			JOIN users u ON r.user_id = u.id, bounds
+			WHERE u.is_active = true
+			  AND r.bounding_box && bounds.geom_4326
+		),
+		lines AS (
+			SELECT id, name, difficulty, length_km,
+			       ST_AsMVTGeom(ST_Transform(ST_Force2D(path), 3857), bounds.geom, $4, $5, true) as geom
+			FROM tile_routes, bounds
+			WHERE path IS NOT NULL
+		),
+		starts AS (
+			SELECT id, name, difficulty, length_km,
+			       ST_AsMVTGeom(ST_Transform(ST_Force2D(start_point), 3857), bounds.geom, $4, $5, true) as geom
+			FROM tile_routes, bounds
+			WHERE start_point IS NOT NULL
+		)
+		SELECT COALESCE((SELECT ST_AsMVT(lines, 'routes', $4, 'geom') FROM lines WHERE geom IS NOT NULL), ''::bytea)
+		    || COALESCE((SELECT ST_AsMVT(starts, 'route_starts', $4, 'geom') FROM starts WHERE geom IS NOT NULL), ''::bytea)
+	`
+
+	var tile []byte
+	ctx := context.Background()
+	err = h.db.QueryRow(ctx, query, z, x, y, TileExtent, TileBuffer).Scan(&tile)
+	if err != nil {
+		log.Printf("ERROR: Failed to generate tile %d/%d/%d: %v", z, x, y, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate tile",
+		})
+		return
+	}
+
+	// Let the map reuse tiles and revalidate them cheaply once they expire
+	sum := sha1.Sum(tile)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", TileCacheMaxAge))
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, MVTContentType, tile)
+}
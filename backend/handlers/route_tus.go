@@ -0,0 +1,652 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"gpxbase/backend/models"
+	"gpxbase/backend/storage"
+)
+
+const (
+	// TusVersion is the version of the tus resumable upload protocol implemented by the tus endpoints
+	TusVersion = "1.0.0"
+	// TusExtensions are the tus protocol extensions supported on top of the core protocol
+	TusExtensions = "creation,expiration,termination"
+	// TusChunkContentType is the required media type of PATCH requests
+	TusChunkContentType = "application/offset+octet-stream"
+	// TusUploadExpiration is how long an incomplete upload is kept after its last chunk
+	TusUploadExpiration = 24 * time.Hour
+	// TusCleanupInterval is how often expired incomplete uploads are deleted
+	TusCleanupInterval = time.Hour
+	// TusClaimTimeout is how long creating the route may take before another request can claim the upload
+	TusClaimTimeout = 30 * time.Minute
+	// TusCompletedUploadRetention is how long a completed upload is kept before it is deleted
+	TusCompletedUploadRetention = 24 * time.Hour
+	// TusMinChunkSize is the smallest chunk accepted unless it completes the upload
+	TusMinChunkSize = 256 << 10
+)
+
+// tusUpload is the state of a resumable upload
+type tusUpload struct {
+	ID            uuid.UUID
+	UserID        string
+	Filename      string
+	RouteMetadata []byte
+	UploadLength  int64
+	UploadOffset  int64
+	ChunkOffsets  []int64
+	ExpiresAt     time.Time
+	RouteID       *uuid.UUID
+}
+
+// checkTusResumable sets the Tus-Resumable response header and rejects requests for another protocol version
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", TusVersion)
+	if c.GetHeader("Tus-Resumable") != TusVersion {
+		c.Header("Tus-Version", TusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Unsupported tus version, expected Tus-Resumable: " + TusVersion,
+		})
+		return false
+	}
+	return true
+}
+
+// parseTusMetadata decodes an Upload-Metadata header: comma-separated keys, each followed by a base64 value
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value for metadata key %q", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// routeRequestFromTusMetadata builds and validates the route metadata sent with a resumable upload
+func routeRequestFromTusMetadata(metadata map[string]string) (*models.RouteCreateRequest, error) {
+	routeReq := &models.RouteCreateRequest{
+		Name:               metadata["name"],
+		Difficulty:         models.DifficultyLevel(metadata["difficulty"]),
+		SceneryDescription: metadata["scenery_description"],
+		AdditionalNotes:    metadata["additional_notes"],
+	}
+	if value, ok := metadata["max_elevation_gain"]; ok {
+		gain, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_elevation_gain")
+		}
+		routeReq.MaxElevationGain = gain
+	}
+	if err := binding.Validator.ValidateStruct(routeReq); err != nil {
+		return nil, err
+	}
+	return routeReq, nil
+}
+
+// GetTusOptions describes the supported tus protocol version, extensions and maximum upload size
+func (h *RouteHandler) GetTusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", TusVersion)
+	c.Header("Tus-Version", TusVersion)
+	c.Header("Tus-Extension", TusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.maxUploadSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateTusUpload starts a resumable upload (tus creation extension). The route metadata (name, difficulty,
+// scenery_description, additional_notes, max_elevation_gain) and filename are passed in Upload-Metadata.
+func (h *RouteHandler) CreateTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: Resumable upload - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	uploadLength, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || uploadLength <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload-Length header must be a positive number of bytes",
+		})
+		return
+	}
+	if uploadLength > h.maxUploadSize {
+		log.Printf("ERROR: Resumable upload too large for user %s: %d bytes, limit %d bytes", userID.(string), uploadLength, h.maxUploadSize)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File exceeds the maximum upload size of %d MB", h.maxUploadSize>>20),
+		})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Upload-Metadata: " + err.Error(),
+		})
+		return
+	}
+	// Validate the route metadata now rather than after the whole file was sent
+	routeReq, err := routeRequestFromTusMetadata(metadata)
+	if err != nil {
+		log.Printf("ERROR: Invalid route metadata for resumable upload by user %s: %v", userID.(string), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid route metadata: " + err.Error(),
+		})
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = "upload"
+	}
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	routeMetadata, err := json.Marshal(routeReq)
+	if err != nil {
+		log.Printf("ERROR: Failed to encode route metadata for user %s: %v", userID.(string), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start upload",
+		})
+		return
+	}
+
+	uploadID := uuid.New()
+	expiresAt := time.Now().Add(TusUploadExpiration)
+
+	ctx := context.Background()
+	_, err = h.db.Exec(ctx, `
+		INSERT INTO route_tus_uploads (id, user_id, filename, route_metadata, upload_length, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uploadID, userID.(string), filename, routeMetadata, uploadLength, expiresAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to save resumable upload for user %s: %v", userID.(string), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start upload",
+		})
+		return
+	}
+
+	log.Printf("INFO: Resumable upload %s started by user %s for file %s (%d bytes)", uploadID.String(), userID.(string), filename, uploadLength)
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+uploadID.String())
+	c.Header("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// getTusUpload loads an upload of the user that is completed or not yet expired, writing the error response
+// and returning nil when there is none
+func (h *RouteHandler) getTusUpload(c *gin.Context) *tusUpload {
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: Resumable upload - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return nil
+	}
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return nil
+	}
+
+	upload := tusUpload{ID: uploadID, UserID: userID.(string)}
+	ctx := context.Background()
+	err = h.db.QueryRow(ctx, `
+		SELECT filename, route_metadata, upload_length, upload_offset, chunk_offsets, expires_at, route_id
+		FROM route_tus_uploads
+		WHERE id = $1 AND user_id = $2
+		  AND (completed_at IS NOT NULL OR expires_at > CURRENT_TIMESTAMP)
+	`, uploadID, upload.UserID).Scan(
+		&upload.Filename, &upload.RouteMetadata, &upload.UploadLength, &upload.UploadOffset,
+		&upload.ChunkOffsets, &upload.ExpiresAt, &upload.RouteID,
+	)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Upload not found or expired",
+			})
+			return nil
+		}
+		log.Printf("ERROR: Failed to fetch resumable upload %s: %v", uploadID.String(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch upload",
+		})
+		return nil
+	}
+	return &upload
+}
+
+// setTusUploadHeaders describes the state of an upload in the response headers
+func setTusUploadHeaders(c *gin.Context, upload *tusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.UploadLength, 10))
+	c.Header("Cache-Control", "no-store")
+	if upload.RouteID != nil {
+		c.Header("X-Route-Id", upload.RouteID.String())
+	} else {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// GetTusUploadOffset returns how many bytes of an upload were received, so the client can resume from there
+func (h *RouteHandler) GetTusUploadOffset(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload := h.getTusUpload(c)
+	if upload == nil {
+		return
+	}
+
+	setTusUploadHeaders(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload appends a chunk at Upload-Offset. Bytes received before a dropped connection are kept. Every
+// chunk but the last must hold at least TusMinChunkSize bytes. Once all bytes are received the file is validated
+// and the route created; the route ID is returned in X-Route-Id. A PATCH without body at the final offset
+// retries the route creation.
+func (h *RouteHandler) PatchTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != TusChunkContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Content-Type must be " + TusChunkContentType,
+		})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload-Offset header must be a non-negative number of bytes",
+		})
+		return
+	}
+
+	upload := h.getTusUpload(c)
+	if upload == nil {
+		return
+	}
+	if upload.RouteID != nil {
+		setTusUploadHeaders(c, upload)
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Upload already completed",
+		})
+		return
+	}
+	if offset != upload.UploadOffset {
+		setTusUploadHeaders(c, upload)
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Upload-Offset %d does not match the %d bytes received", offset, upload.UploadOffset),
+		})
+		return
+	}
+
+	if upload.UploadOffset < upload.UploadLength {
+		if !h.storeTusChunk(c, upload) {
+			return
+		}
+	}
+
+	if upload.UploadOffset == upload.UploadLength {
+		h.completeTusUpload(c, upload)
+		return
+	}
+
+	setTusUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// storeTusChunk stores the request body as the next chunk of the upload and advances its offset
+func (h *RouteHandler) storeTusChunk(c *gin.Context, upload *tusUpload) bool {
+	spool, err := os.CreateTemp("", "route-chunk-*")
+	if err != nil {
+		log.Printf("ERROR: Failed to create temporary file for upload %s: %v", upload.ID.String(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store chunk",
+		})
+		return false
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	// Keep whatever arrived before a read error, the client resumes from the new offset
+	remaining := upload.UploadLength - upload.UploadOffset
+	received, readErr := io.Copy(spool, io.LimitReader(c.Request.Body, remaining+1))
+	if received > remaining {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Chunk exceeds the Upload-Length of the upload",
+		})
+		return false
+	}
+	if readErr != nil {
+		log.Printf("WARN: Chunk of upload %s interrupted after %d bytes: %v", upload.ID.String(), received, readErr)
+	}
+	if received == 0 {
+		if readErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read chunk",
+			})
+			return false
+		}
+		return true
+	}
+	// Tiny chunks would each cost a storage object and an entry in chunk_offsets
+	if received < TusMinChunkSize && received < remaining {
+		log.Printf("WARN: Rejected %d byte chunk of upload %s at offset %d", received, upload.ID.String(), upload.UploadOffset)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Chunks other than the last must be at least %d KiB", TusMinChunkSize>>10),
+		})
+		return false
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		log.Printf("ERROR: Failed to rewind chunk of upload %s: %v", upload.ID.String(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store chunk",
+		})
+		return false
+	}
+
+	// Lock the upload while the chunk is stored, so a request resuming at the same offset after a dropped
+	// connection waits instead of overwriting the chunk object
+	ctx := context.Background()
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		log.Printf("ERROR: Failed to begin transaction for upload %s: %v", upload.ID.String(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store chunk",
+		})
+		return false
+	}
+	defer tx.Rollback(ctx)
+
+	var currentOffset int64
+	err = tx.QueryRow(ctx, `
+		SELECT upload_offset FROM route_tus_uploads
+		WHERE id = $1 AND completed_at IS NULL
+		FOR UPDATE
+	`, upload.ID).Scan(&currentOffset)
+	if err != nil || currentOffset != upload.UploadOffset {
+		if err == nil {
+			err = fmt.Errorf("offset changed to %d by a concurrent request", currentOffset)
+		}
+		log.Printf("WARN: Failed to lock upload %s at offset %d: %v", upload.ID.String(), upload.UploadOffset, err)
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Failed to record chunk, check the offset and retry",
+		})
+		return false
+	}
+
+	chunkKey := storage.GenerateChunkKey(upload.UserID, upload.ID.String(), upload.UploadOffset)
+	if err := h.storage.UploadFile(chunkKey, spool, TusChunkContentType); err != nil {
+		log.Printf("ERROR: Failed to store chunk %s: %v", chunkKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to store chunk",
+		})
+		return false
+	}
+
+	expiresAt := time.Now().Add(TusUploadExpiration)
+	_, err = tx.Exec(ctx, `
+		UPDATE route_tus_uploads
+		SET upload_offset = upload_offset + $2,
+		    chunk_offsets = array_append(chunk_offsets, upload_offset),
+		    expires_at = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, upload.ID, received, expiresAt)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		// The row is still locked or was never updated, so no other request owns this chunk yet
+		log.Printf("ERROR: Failed to record chunk of upload %s at offset %d: %v", upload.ID.String(), upload.UploadOffset, err)
+		h.deleteUploadObject(chunkKey)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record chunk",
+		})
+		return false
+	}
+
+	log.Printf("INFO: Stored %d bytes of upload %s at offset %d", received, upload.ID.String(), upload.UploadOffset)
+	upload.UploadOffset += received
+	upload.ChunkOffsets = append(upload.ChunkOffsets, upload.UploadOffset-received)
+	upload.ExpiresAt = expiresAt
+	return true
+}
+
+// completeTusUpload assembles the chunks of a fully received upload and creates the route from it
+func (h *RouteHandler) completeTusUpload(c *gin.Context, upload *tusUpload) {
+	var routeReq models.RouteCreateRequest
+	if err := json.Unmarshal(upload.RouteMetadata, &routeReq); err != nil {
+		log.Printf("ERROR: Failed to decode route metadata of upload %s: %v", upload.ID.String(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read route metadata",
+		})
+		return
+	}
+
+	// Claim the upload so concurrent requests cannot create the route twice. The claim of a request that died
+	// while creating the route expires after TusClaimTimeout
+	ctx := context.Background()
+	tag, err := h.db.Exec(ctx, `
+		UPDATE route_tus_uploads SET claimed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND completed_at IS NULL AND (claimed_at IS NULL OR claimed_at <= $2)
+	`, upload.ID, time.Now().Add(-TusClaimTimeout))
+	if err != nil || tag.RowsAffected() == 0 {
+		log.Printf("WARN: Upload %s is already being completed: %v", upload.ID.String(), err)
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Upload is already being completed",
+		})
+		return
+	}
+
+	spool, err := os.CreateTemp("", "route-upload-*")
+	if err != nil {
+		log.Printf("ERROR: Failed to create temporary file for upload %s: %v", upload.ID.String(), err)
+		h.releaseTusUpload(upload.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	for _, chunkOffset := range upload.ChunkOffsets {
+		if err := h.appendTusChunk(spool, upload, chunkOffset); err != nil {
+			log.Printf("ERROR: Failed to assemble upload %s: %v", upload.ID.String(), err)
+			h.releaseTusUpload(upload.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to read uploaded file",
+			})
+			return
+		}
+	}
+
+	route := h.createRouteFromFile(c, upload.UserID, spool, upload.Filename, upload.UploadLength, &routeReq)
+	if route == nil {
+		// An invalid or duplicate file is rejected again on retry, only server errors keep the upload
+		if c.Writer.Status() < http.StatusInternalServerError {
+			h.discardTusUpload(upload)
+		} else {
+			h.releaseTusUpload(upload.ID)
+		}
+		return
+	}
+
+	if _, err := h.db.Exec(ctx, `
+		UPDATE route_tus_uploads
+		SET route_id = $1, completed_at = CURRENT_TIMESTAMP, claimed_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`, route.ID, upload.ID); err != nil {
+		log.Printf("WARN: Failed to link upload %s to route %s: %v", upload.ID.String(), route.ID.String(), err)
+	}
+	h.deleteTusChunks(upload.UserID, upload.ID, upload.ChunkOffsets)
+
+	log.Printf("INFO: Route created successfully from resumable upload %s for user %s: %s (ID: %s)", upload.ID.String(), upload.UserID, route.Name, route.ID.String())
+	upload.RouteID = &route.ID
+	setTusUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// appendTusChunk copies one stored chunk to the end of the assembled file
+func (h *RouteHandler) appendTusChunk(dst io.Writer, upload *tusUpload, chunkOffset int64) error {
+	chunk, _, _, err := h.storage.OpenFile(storage.GenerateChunkKey(upload.UserID, upload.ID.String(), chunkOffset))
+	if err != nil {
+		return fmt.Errorf("failed to open chunk at offset %d: %w", chunkOffset, err)
+	}
+	defer chunk.Close()
+	if _, err := io.Copy(dst, chunk); err != nil {
+		return fmt.Errorf("failed to read chunk at offset %d: %w", chunkOffset, err)
+	}
+	return nil
+}
+
+// releaseTusUpload reopens a claimed upload after a failed completion so the client can retry
+func (h *RouteHandler) releaseTusUpload(uploadID uuid.UUID) {
+	ctx := context.Background()
+	if _, err := h.db.Exec(ctx, `UPDATE route_tus_uploads SET claimed_at = NULL WHERE id = $1`, uploadID); err != nil {
+		log.Printf("WARN: Failed to release resumable upload %s: %v", uploadID.String(), err)
+	}
+}
+
+// discardTusUpload deletes a rejected upload and its chunks
+func (h *RouteHandler) discardTusUpload(upload *tusUpload) {
+	h.deleteTusChunks(upload.UserID, upload.ID, upload.ChunkOffsets)
+	ctx := context.Background()
+	if _, err := h.db.Exec(ctx, `DELETE FROM route_tus_uploads WHERE id = $1`, upload.ID); err != nil {
+		log.Printf("WARN: Failed to delete resumable upload %s: %v", upload.ID.String(), err)
+	}
+}
+
+// deleteTusChunks removes the stored chunks of an upload
+func (h *RouteHandler) deleteTusChunks(userID string, uploadID uuid.UUID, chunkOffsets []int64) {
+	for _, chunkOffset := range chunkOffsets {
+		h.deleteUploadObject(storage.GenerateChunkKey(userID, uploadID.String(), chunkOffset))
+	}
+}
+
+// DeleteTusUpload cancels an incomplete upload and deletes its chunks (tus termination extension)
+func (h *RouteHandler) DeleteTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload := h.getTusUpload(c)
+	if upload == nil {
+		return
+	}
+
+	ctx := context.Background()
+	tag, err := h.db.Exec(ctx, `
+		DELETE FROM route_tus_uploads
+		WHERE id = $1 AND completed_at IS NULL AND (claimed_at IS NULL OR claimed_at <= $2)
+	`, upload.ID, time.Now().Add(-TusClaimTimeout))
+	if err != nil {
+		log.Printf("ERROR: Failed to delete resumable upload %s: %v", upload.ID.String(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete upload",
+		})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Upload is being completed or already completed",
+		})
+		return
+	}
+	h.deleteTusChunks(upload.UserID, upload.ID, upload.ChunkOffsets)
+
+	log.Printf("INFO: Resumable upload %s deleted by user %s", upload.ID.String(), upload.UserID)
+	c.Status(http.StatusNoContent)
+}
+
+// StartTusUploadCleanup periodically deletes incomplete uploads past their expiry or claim timeout, along with
+// their chunks, and forgets completed uploads after TusCompletedUploadRetention
+func (h *RouteHandler) StartTusUploadCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			h.cleanupExpiredTusUploads()
+			<-ticker.C
+		}
+	}()
+}
+
+// cleanupExpiredTusUploads deletes the expired incomplete uploads, the uploads whose completion died and the
+// completed uploads past their retention
+func (h *RouteHandler) cleanupExpiredTusUploads() {
+	ctx := context.Background()
+	tag, err := h.db.Exec(ctx, `
+		DELETE FROM route_tus_uploads
+		WHERE completed_at IS NOT NULL AND completed_at <= $1
+	`, time.Now().Add(-TusCompletedUploadRetention))
+	if err != nil {
+		log.Printf("ERROR: Failed to delete completed resumable uploads: %v", err)
+	} else if tag.RowsAffected() > 0 {
+		log.Printf("INFO: Deleted %d completed resumable uploads", tag.RowsAffected())
+	}
+
+	// A claim older than TusClaimTimeout belongs to a request that crashed or died while creating the route
+	rows, err := h.db.Query(ctx, `
+		DELETE FROM route_tus_uploads
+		WHERE completed_at IS NULL
+		  AND (expires_at <= CURRENT_TIMESTAMP OR claimed_at <= $1)
+		RETURNING id, user_id, chunk_offsets
+	`, time.Now().Add(-TusClaimTimeout))
+	if err != nil {
+		log.Printf("ERROR: Failed to delete expired resumable uploads: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var uploadID, userID uuid.UUID
+		var chunkOffsets []int64
+		if err := rows.Scan(&uploadID, &userID, &chunkOffsets); err != nil {
+			log.Printf("ERROR: Failed to scan expired resumable upload: %v", err)
+			return
+		}
+		h.deleteTusChunks(userID.String(), uploadID, chunkOffsets)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Failed to read expired resumable uploads: %v", err)
+		return
+	}
+
+	if count > 0 {
+		log.Printf("INFO: Deleted %d expired resumable uploads", count)
+	}
+}
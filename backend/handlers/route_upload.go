@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gpxbase/backend/models"
+	"gpxbase/backend/storage"
+)
+
+const (
+	// UploadURLExpirationMinutes is how long a direct upload URL accepts the file
+	UploadURLExpirationMinutes = 15
+	// UploadCompletionGracePeriod is how long after the URL expiry an upload can still be completed, so a PUT
+	// started just before the expiry can finish
+	UploadCompletionGracePeriod = 10 * time.Minute
+	// CompletedUploadRetention is how long a completed direct upload is kept before it is deleted
+	CompletedUploadRetention = 24 * time.Hour
+	// RouteUploadCleanupInterval is how often expired pending direct uploads are deleted
+	RouteUploadCleanupInterval = time.Hour
+)
+
+// CreateRouteUpload starts a direct upload: it returns a presigned URL the client PUTs the activity file to,
+// so large files go to storage without passing through the API
+func (h *RouteHandler) CreateRouteUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: Route upload - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	var req models.RouteUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("ERROR: Failed to parse upload request for user %s: %v", userID.(string), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid upload request: " + err.Error(),
+		})
+		return
+	}
+	if req.FileSize > h.maxUploadSize {
+		log.Printf("ERROR: Announced upload too large for user %s: %d bytes, limit %d bytes", userID.(string), req.FileSize, h.maxUploadSize)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File exceeds the maximum upload size of %d MB", h.maxUploadSize>>20),
+		})
+		return
+	}
+
+	uploadID := uuid.New()
+	objectKey := storage.GenerateUploadKey(userID.(string), uploadID.String())
+	duration := time.Duration(UploadURLExpirationMinutes) * time.Minute
+	expiresAt := time.Now().Add(duration)
+	completeBy := expiresAt.Add(UploadCompletionGracePeriod)
+
+	uploadURL, err := h.storage.GetPresignedUploadURL(objectKey, duration)
+	if err != nil {
+		log.Printf("ERROR: Failed to generate upload URL for %s: %v", objectKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate upload URL",
+		})
+		return
+	}
+
+	ctx := context.Background()
+	_, err = h.db.Exec(ctx, `
+		INSERT INTO route_uploads (id, user_id, filename, object_key, expires_at, complete_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uploadID, userID.(string), req.Filename, objectKey, expiresAt, completeBy)
+	if err != nil {
+		log.Printf("ERROR: Failed to save upload %s for user %s: %v", uploadID.String(), userID.(string), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start upload",
+		})
+		return
+	}
+
+	log.Printf("INFO: Upload %s started by user %s for file %s", uploadID.String(), userID.(string), req.Filename)
+
+	c.JSON(http.StatusCreated, models.RouteUploadResponse{
+		UploadID:    uploadID,
+		UploadURL:   uploadURL,
+		Method:      http.MethodPut,
+		ExpiresAt:   expiresAt,
+		CompleteBy:  completeBy,
+		MaxFileSize: h.maxUploadSize,
+	})
+}
+
+// CompleteRouteUpload fetches a file uploaded with CreateRouteUpload, validates it and creates the route
+// with the metadata of the request body
+func (h *RouteHandler) CompleteRouteUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: Complete upload - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid upload ID",
+		})
+		return
+	}
+
+	var routeReq models.RouteCreateRequest
+	if err := c.ShouldBindJSON(&routeReq); err != nil {
+		log.Printf("ERROR: Failed to parse route metadata for upload %s: %v", uploadID.String(), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid route metadata: " + err.Error(),
+		})
+		return
+	}
+
+	// Claim the upload so concurrent completions cannot create the route twice. The upload URL may have
+	// expired meanwhile, a PUT started before the expiry can still be completing.
+	var filename, objectKey string
+	ctx := context.Background()
+	err = h.db.QueryRow(ctx, `
+		UPDATE route_uploads SET completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND completed_at IS NULL AND complete_by > CURRENT_TIMESTAMP
+		RETURNING filename, object_key
+	`, uploadID, userID.(string)).Scan(&filename, &objectKey)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			log.Printf("WARN: Upload %s not found, expired or already completed for user %s", uploadID.String(), userID.(string))
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Upload not found, expired or already completed",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to fetch upload %s for user %s: %v", uploadID.String(), userID.(string), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch upload",
+		})
+		return
+	}
+
+	log.Printf("INFO: Completing upload %s (%s) for user %s", uploadID.String(), filename, userID.(string))
+
+	file, _, size, err := h.storage.OpenFile(objectKey)
+	if err != nil {
+		h.releaseRouteUpload(uploadID)
+		if errors.Is(err, storage.ErrFileNotFound) {
+			log.Printf("WARN: Upload %s completed before the file was uploaded", uploadID.String())
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "File has not been uploaded yet",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to open uploaded file %s: %v", objectKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer file.Close()
+
+	// Presigned PUTs cannot enforce a size, so the limit is checked here
+	if size > h.maxUploadSize {
+		log.Printf("ERROR: Uploaded file %s too large: %d bytes, limit %d bytes", objectKey, size, h.maxUploadSize)
+		h.discardRouteUpload(uploadID, objectKey)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File exceeds the maximum upload size of %d MB", h.maxUploadSize>>20),
+		})
+		return
+	}
+
+	// Spool the file to disk, it is read several times while it is detected, decoded and stored
+	spool, err := os.CreateTemp("", "route-upload-*")
+	if err != nil {
+		log.Printf("ERROR: Failed to create temporary file for upload %s: %v", uploadID.String(), err)
+		h.releaseRouteUpload(uploadID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err = io.Copy(spool, io.LimitReader(file, h.maxUploadSize+1))
+	if err == nil && size > h.maxUploadSize {
+		err = fmt.Errorf("file exceeds %d bytes", h.maxUploadSize)
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch uploaded file %s: %v", objectKey, err)
+		h.releaseRouteUpload(uploadID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+
+	route := h.createRouteFromFile(c, userID.(string), spool, filename, size, &routeReq)
+	if route == nil {
+		// An invalid or duplicate file is rejected again on retry, only server errors keep the upload
+		if c.Writer.Status() < http.StatusInternalServerError {
+			h.discardRouteUpload(uploadID, objectKey)
+		} else {
+			h.releaseRouteUpload(uploadID)
+		}
+		return
+	}
+
+	if _, err := h.db.Exec(ctx, `UPDATE route_uploads SET route_id = $1 WHERE id = $2`, route.ID, uploadID); err != nil {
+		log.Printf("WARN: Failed to link upload %s to route %s: %v", uploadID.String(), route.ID.String(), err)
+	}
+	// The file was copied to the route's own keys, the staging object is no longer needed
+	h.deleteUploadObject(objectKey)
+
+	response := route.ToResponse()
+	log.Printf("INFO: Route created successfully from upload %s for user %s: %s (ID: %s)", uploadID.String(), userID.(string), route.Name, route.ID.String())
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Route created successfully",
+		"route":   response,
+	})
+}
+
+// releaseRouteUpload reopens a claimed upload after a failed completion so the client can retry
+func (h *RouteHandler) releaseRouteUpload(uploadID uuid.UUID) {
+	ctx := context.Background()
+	if _, err := h.db.Exec(ctx, `UPDATE route_uploads SET completed_at = NULL WHERE id = $1`, uploadID); err != nil {
+		log.Printf("WARN: Failed to release upload %s: %v", uploadID.String(), err)
+	}
+}
+
+// discardRouteUpload deletes a rejected upload and its staging object
+func (h *RouteHandler) discardRouteUpload(uploadID uuid.UUID, objectKey string) {
+	h.deleteUploadObject(objectKey)
+	ctx := context.Background()
+	if _, err := h.db.Exec(ctx, `DELETE FROM route_uploads WHERE id = $1`, uploadID); err != nil {
+		log.Printf("WARN: Failed to delete upload %s: %v", uploadID.String(), err)
+	}
+}
+
+// deleteUploadObject removes the staging object of a direct upload
+func (h *RouteHandler) deleteUploadObject(objectKey string) {
+	if err := h.storage.DeleteFile(objectKey); err != nil {
+		log.Printf("WARN: Failed to delete uploaded file %s: %v", objectKey, err)
+	}
+}
+
+// StartRouteUploadCleanup periodically deletes direct uploads that were never completed, along with their staging
+// objects, and forgets completed uploads after CompletedUploadRetention
+func (h *RouteHandler) StartRouteUploadCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			h.cleanupExpiredRouteUploads()
+			<-ticker.C
+		}
+	}()
+}
+
+// cleanupExpiredRouteUploads deletes the pending direct uploads past their completion deadline and the
+// completed uploads past their retention
+func (h *RouteHandler) cleanupExpiredRouteUploads() {
+	// A completed upload without a route was claimed by a request that died before creating it, so its staging
+	// object was never deleted
+	h.deleteRouteUploads("completed", `
+		DELETE FROM route_uploads
+		WHERE completed_at IS NOT NULL AND completed_at <= $1
+		RETURNING CASE WHEN route_id IS NULL THEN object_key END
+	`, time.Now().Add(-CompletedUploadRetention))
+
+	h.deleteRouteUploads("expired", `
+		DELETE FROM route_uploads
+		WHERE completed_at IS NULL AND complete_by <= CURRENT_TIMESTAMP
+		RETURNING object_key
+	`)
+}
+
+// deleteRouteUploads runs a query deleting direct uploads and returning the staging object key of each, or NULL
+// when the object is already gone, and deletes the returned objects
+func (h *RouteHandler) deleteRouteUploads(kind string, query string, args ...interface{}) {
+	ctx := context.Background()
+	rows, err := h.db.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete %s direct uploads: %v", kind, err)
+		return
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var objectKey *string
+		if err := rows.Scan(&objectKey); err != nil {
+			log.Printf("ERROR: Failed to scan %s direct upload: %v", kind, err)
+			return
+		}
+		if objectKey != nil {
+			h.deleteUploadObject(*objectKey)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Failed to read %s direct uploads: %v", kind, err)
+		return
+	}
+
+	if count > 0 {
+		log.Printf("INFO: Deleted %d %s direct uploads", count, kind)
+	}
+}
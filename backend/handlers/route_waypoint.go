@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gpxbase/backend/models"
+	"gpxbase/backend/utils"
+)
+
+// routeWaypointColumns selects a route_waypoints row in the order expected by scanRouteWaypoint
+const routeWaypointColumns = `id, route_id, position, name, symbol, description,
+	ST_Y(location) as latitude, ST_X(location) as longitude,
+	elevation, recorded_at, created_at, updated_at`
+
+// scanRouteWaypoint reads a row selected with routeWaypointColumns
+func scanRouteWaypoint(row pgx.Row) (models.RouteWaypoint, error) {
+	var wp models.RouteWaypoint
+	err := row.Scan(
+		&wp.ID, &wp.RouteID, &wp.Position, &wp.Name, &wp.Symbol, &wp.Description,
+		&wp.Latitude, &wp.Longitude,
+		&wp.Elevation, &wp.Time, &wp.CreatedAt, &wp.UpdatedAt,
+	)
+	return wp, err
+}
+
+// fetchRouteWaypoints returns the waypoints of a route in display order
+func fetchRouteWaypoints(ctx context.Context, db *pgxpool.Pool, routeID string) ([]models.RouteWaypoint, error) {
+	query := `SELECT ` + routeWaypointColumns + `
+		FROM route_waypoints
+		WHERE route_id = $1
+		ORDER BY position, created_at`
+
+	rows, err := db.Query(ctx, query, routeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	waypoints := []models.RouteWaypoint{}
+	for rows.Next() {
+		wp, err := scanRouteWaypoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		waypoints = append(waypoints, wp)
+	}
+	return waypoints, rows.Err()
+}
+
+// exportWaypoints converts stored waypoints into the points written by route exports
+func exportWaypoints(waypoints []models.RouteWaypoint) []utils.Waypoint {
+	points := make([]utils.Waypoint, 0, len(waypoints))
+	for _, wp := range waypoints {
+		point := utils.Waypoint{
+			Lat:         wp.Latitude,
+			Lon:         wp.Longitude,
+			Ele:         wp.Elevation,
+			Symbol:      wp.Symbol,
+			Description: wp.Description,
+		}
+		if wp.Name != "" {
+			name := wp.Name
+			point.Name = &name
+		}
+		if wp.Time != nil {
+			t := wp.Time.UTC().Format(time.RFC3339)
+			point.Time = &t
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// userOwnsRoute reports whether the route exists and belongs to the user
+func (h *RouteHandler) userOwnsRoute(ctx context.Context, routeID, userID string) (bool, error) {
+	var owned bool
+	err := h.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM routes WHERE id = $1 AND user_id = $2)", routeID, userID).Scan(&owned)
+	return owned, err
+}
+
+// GetRouteWaypoints lists the waypoints of one of the user's routes
+func (h *RouteHandler) GetRouteWaypoints(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: GetRouteWaypoints - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	routeID := c.Param("id")
+	ctx := context.Background()
+
+	owned, err := h.userOwnsRoute(ctx, routeID, userID.(string))
+	if err != nil {
+		log.Printf("ERROR: Failed to check route %s for user %s: %v", routeID, userID.(string), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch waypoints",
+		})
+		return
+	}
+	if !owned {
+		log.Printf("WARN: Route not found: %s for user %s", routeID, userID.(string))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route not found",
+		})
+		return
+	}
+
+	waypoints, err := fetchRouteWaypoints(ctx, h.db, routeID)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch waypoints of route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch waypoints",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"waypoints": waypoints,
+	})
+}
+
+// CreateRouteWaypoint adds a waypoint at the end of one of the user's routes
+func (h *RouteHandler) CreateRouteWaypoint(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: CreateRouteWaypoint - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	routeID := c.Param("id")
+
+	var req models.RouteWaypointCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("ERROR: Failed to parse waypoint request for user %s: %v", userID.(string), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid waypoint data: " + err.Error(),
+		})
+		return
+	}
+
+	ctx := context.Background()
+	owned, err := h.userOwnsRoute(ctx, routeID, userID.(string))
+	if err != nil {
+		log.Printf("ERROR: Failed to check route %s for user %s: %v", routeID, userID.(string), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create waypoint",
+		})
+		return
+	}
+	if !owned {
+		log.Printf("WARN: Route not found: %s for user %s", routeID, userID.(string))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route not found",
+		})
+		return
+	}
+
+	// New waypoints are appended after the existing ones
+	query := `
+		INSERT INTO route_waypoints (route_id, position, name, symbol, description, location, elevation, recorded_at)
+		SELECT $1, COALESCE(MAX(position) + 1, 0), $2, $3, $4,
+		       ST_SetSRID(ST_MakePoint($5, $6), 4326), $7, $8
+		FROM route_waypoints
+		WHERE route_id = $1
+		RETURNING ` + routeWaypointColumns
+
+	waypoint, err := scanRouteWaypoint(h.db.QueryRow(ctx, query,
+		routeID, req.Name, req.Symbol, req.Description,
+		*req.Longitude, *req.Latitude, req.Elevation, req.Time,
+	))
+	if err != nil {
+		log.Printf("ERROR: Failed to create waypoint for route %s: %v", routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create waypoint",
+		})
+		return
+	}
+
+	log.Printf("INFO: Waypoint %s created for route %s by user %s", waypoint.ID, routeID, userID.(string))
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Waypoint created successfully",
+		"waypoint": waypoint,
+	})
+}
+
+// UpdateRouteWaypoint updates the fields of a waypoint that are present in the request
+func (h *RouteHandler) UpdateRouteWaypoint(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: UpdateRouteWaypoint - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	routeID := c.Param("id")
+	waypointID := c.Param("waypointId")
+
+	var req models.RouteWaypointUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("ERROR: Failed to parse waypoint update request for user %s: %v", userID.(string), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid update data: " + err.Error(),
+		})
+		return
+	}
+
+	// Build dynamic update query
+	setParts := []string{"updated_at = NOW()"}
+	args := []interface{}{waypointID, routeID, userID.(string)}
+	argIndex := 4
+
+	addField := func(column string, value interface{}) {
+		setParts = append(setParts, fmt.Sprintf("%s = $%d", column, argIndex))
+		args = append(args, value)
+		argIndex++
+	}
+	if req.Name != nil {
+		addField("name", *req.Name)
+	}
+	if req.Symbol != nil {
+		addField("symbol", *req.Symbol)
+	}
+	if req.Description != nil {
+		addField("description", *req.Description)
+	}
+	if req.Elevation != nil {
+		addField("elevation", *req.Elevation)
+	}
+	if req.Time != nil {
+		addField("recorded_at", *req.Time)
+	}
+	if req.Position != nil {
+		addField("position", *req.Position)
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		// Keep the current value of a coordinate that is not being changed
+		lon, lat := "ST_X(location)", "ST_Y(location)"
+		if req.Longitude != nil {
+			lon = fmt.Sprintf("$%d::double precision", argIndex)
+			args = append(args, *req.Longitude)
+			argIndex++
+		}
+		if req.Latitude != nil {
+			lat = fmt.Sprintf("$%d::double precision", argIndex)
+			args = append(args, *req.Latitude)
+			argIndex++
+		}
+		setParts = append(setParts, fmt.Sprintf("location = ST_SetSRID(ST_MakePoint(%s, %s), 4326)", lon, lat))
+	}
+
+	if len(setParts) == 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No fields to update",
+		})
+		return
+	}
+
+	query := "UPDATE route_waypoints SET " + strings.Join(setParts, ", ") + `
+		WHERE id = $1 AND route_id = $2
+		  AND route_id IN (SELECT id FROM routes WHERE user_id = $3)
+		RETURNING ` + routeWaypointColumns
+
+	ctx := context.Background()
+	waypoint, err := scanRouteWaypoint(h.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			log.Printf("WARN: Waypoint %s not found on route %s for user %s", waypointID, routeID, userID.(string))
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Waypoint not found",
+			})
+			return
+		}
+		log.Printf("ERROR: Failed to update waypoint %s of route %s: %v", waypointID, routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update waypoint",
+		})
+		return
+	}
+
+	log.Printf("INFO: Waypoint %s of route %s updated by user %s", waypointID, routeID, userID.(string))
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Waypoint updated successfully",
+		"waypoint": waypoint,
+	})
+}
+
+// DeleteRouteWaypoint removes a waypoint from one of the user's routes
+func (h *RouteHandler) DeleteRouteWaypoint(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.Printf("ERROR: DeleteRouteWaypoint - User not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User not authenticated",
+		})
+		return
+	}
+
+	routeID := c.Param("id")
+	waypointID := c.Param("waypointId")
+
+	query := `
+		DELETE FROM route_waypoints w
+		USING routes r
+		WHERE w.id = $1 AND w.route_id = $2
+		  AND r.id = w.route_id AND r.user_id = $3
+	`
+
+	ctx := context.Background()
+	result, err := h.db.Exec(ctx, query, waypointID, routeID, userID.(string))
+	if err != nil {
+		log.Printf("ERROR: Failed to delete waypoint %s of route %s: %v", waypointID, routeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete waypoint",
+		})
+		return
+	}
+	if result.RowsAffected() == 0 {
+		log.Printf("WARN: Waypoint %s not found on route %s for user %s", waypointID, routeID, userID.(string))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Waypoint not found",
+		})
+		return
+	}
+
+	log.Printf("INFO: Waypoint %s of route %s deleted by user %s", waypointID, routeID, userID.(string))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Waypoint deleted successfully",
+	})
+}
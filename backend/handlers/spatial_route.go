@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gpxbase/backend/models"
+	"gpxbase/backend/services"
 )
 
 type SpatialRouteHandler struct {
@@ -29,6 +30,20 @@ type BoundsParams struct {
 	MaxLng float64
 }
 
+// CrossesAntimeridian reports whether the bounds wrap around 180° longitude (min_lng is east of max_lng)
+func (b *BoundsParams) CrossesAntimeridian() bool {
+	return b.MinLng > b.MaxLng
+}
+
+// BoundsMatchMode selects which part of a route has to be inside the bounds
+type BoundsMatchMode string
+
+const (
+	BoundsMatchCenter     BoundsMatchMode = "center"     // center point within the bounds
+	BoundsMatchIntersects BoundsMatchMode = "intersects" // any part of the path within the bounds
+	BoundsMatchContains   BoundsMatchMode = "contains"   // whole path within the bounds
+)
+
 type PaginationParams struct {
 	Page  int
 	Limit int
@@ -94,8 +109,9 @@ func validateAndGetBoundsParameters(c *gin.Context) (*BoundsParams, error) {
 	if minLat >= maxLat {
 		return nil, fmt.Errorf("Invalid bounds: min_lat must be less than max_lat")
 	}
-	if minLng >= maxLng {
-		return nil, fmt.Errorf("Invalid bounds: min_lng must be less than max_lng")
+	// min_lng greater than max_lng is a viewport across the antimeridian
+	if minLng == maxLng {
+		return nil, fmt.Errorf("Invalid bounds: min_lng must differ from max_lng")
 	}
 
 	return &BoundsParams{
@@ -125,6 +141,74 @@ func validateAndGetPaginationParameters(c *gin.Context) *PaginationParams {
 	}
 }
 
+// validateAndGetBoundsMatchMode reads the match parameter, defaulting to the route center point
+func validateAndGetBoundsMatchMode(c *gin.Context) (BoundsMatchMode, error) {
+	switch mode := BoundsMatchMode(c.DefaultQuery("match", string(BoundsMatchCenter))); mode {
+	case BoundsMatchCenter, BoundsMatchIntersects, BoundsMatchContains:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Invalid match parameter: must be center, intersects or contains")
+	}
+}
+
+// boundsEnvelopeSQL returns the bounds as a geometry using the parameters $1-$4 (min_lng, min_lat, max_lng, max_lat).
+// Bounds across the antimeridian are split into one envelope on each side of it.
+func boundsEnvelopeSQL(bounds *BoundsParams) string {
+	if bounds.CrossesAntimeridian() {
+		return "ST_Collect(ST_MakeEnvelope($1, $2, 180, $4, 4326), ST_MakeEnvelope(-180, $2, $3, $4, 4326))"
+	}
+	return "ST_MakeEnvelope($1, $2, $3, $4, 4326)"
+}
+
+// boundsConditionSQL returns the WHERE condition matching routes (aliased r) to the bounds in the given mode
+func boundsConditionSQL(bounds *BoundsParams, mode BoundsMatchMode) string {
+	envelope := boundsEnvelopeSQL(bounds)
+	switch mode {
+	case BoundsMatchIntersects:
+		return "ST_Intersects(r.original_geometry, " + envelope + ")"
+	case BoundsMatchContains:
+		return "ST_CoveredBy(r.original_geometry, " + envelope + ")"
+	default:
+		return "ST_Within(r.center_point, " + envelope + ")"
+	}
+}
+
+// validateAndGetSimplificationLevel picks the level of detail for the zoom or tolerance (in meters) parameter.
+// The zoom is converted to the ground resolution at the given latitude. It returns nil when neither is set.
+func validateAndGetSimplificationLevel(c *gin.Context, latitude float64) (*services.SimplificationLevel, error) {
+	zoomStr := c.Query("zoom")
+	toleranceStr := c.Query("tolerance")
+
+	var tolerance float64
+	switch {
+	case toleranceStr != "":
+		t, err := strconv.ParseFloat(toleranceStr, 64)
+		if err != nil || t <= 0 {
+			return nil, fmt.Errorf("Invalid tolerance parameter: must be a positive number of meters")
+		}
+		tolerance = t
+	case zoomStr != "":
+		zoom, err := strconv.ParseFloat(zoomStr, 64)
+		if err != nil || zoom < 0 || zoom > services.MaxMapZoom {
+			return nil, fmt.Errorf("Invalid zoom parameter: must be between 0 and %d", services.MaxMapZoom)
+		}
+		tolerance = services.MetersPerPixel(zoom, latitude)
+	default:
+		return nil, nil
+	}
+
+	level := services.SelectSimplificationLevel(tolerance)
+	return &level, nil
+}
+
+// simplifiedPathColumn returns the column holding the path for a level of detail, the default path for nil
+func simplifiedPathColumn(level *services.SimplificationLevel) string {
+	if level == nil {
+		return "simplified_path"
+	}
+	return level.Column
+}
+
 // GetRoutesInBounds retrieves routes whose center points are within the specified map bounds
 func (h *SpatialRouteHandler) GetRoutesInBounds(c *gin.Context) {
 	log.Printf("INFO: Fetching routes within map bounds")
@@ -138,32 +222,67 @@ func (h *SpatialRouteHandler) GetRoutesInBounds(c *gin.Context) {
 
 	pagination := validateAndGetPaginationParameters(c)
 
+	match, err := validateAndGetBoundsMatchMode(c)
+	if err != nil {
+		log.Printf("ERROR: Invalid match parameter: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	boundsSQL := boundsConditionSQL(bounds, match)
+
+	// Level of detail of the returned paths, for the zoom at the center of the map
+	level, err := validateAndGetSimplificationLevel(c, (bounds.MinLat+bounds.MaxLat)/2)
+	if err != nil {
+		log.Printf("ERROR: Invalid simplification parameters: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Effort filters (heart rate, power, sustained grade) shared with the route listing
+	filterSQL, filterArgs, err := effortFilterSQL(c, 5)
+	if err != nil {
+		log.Printf("ERROR: Invalid effort filter: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Clustered mode counts every route in the bounds instead of returning a page of them
+	if cluster, _ := strconv.ParseBool(c.Query("cluster")); cluster {
+		h.getRouteClusters(c, bounds, boundsSQL, filterSQL, filterArgs)
+		return
+	}
+
 	// Create bounding box polygon for PostGIS query
 	// ST_MakeEnvelope creates a rectangular polygon from min/max coordinates
 	query := `
 		SELECT r.id, r.user_id, r.name, r.difficulty, r.scenery_description, r.additional_notes,
-		       r.max_elevation_gain, r.estimated_duration,
-		       r.average_speed, r.start_time, r.end_time, r.like_count, r.save_count,
+		       r.max_elevation_gain, r.total_ascent, r.total_descent, r.min_elevation, r.max_elevation,
+		       r.estimated_duration,
+		       r.average_speed, r.moving_time_seconds, r.stopped_time_seconds, r.elapsed_time_seconds,
+		       r.moving_average_speed, r.max_speed,
+		       r.avg_heart_rate, r.max_heart_rate, r.avg_cadence, r.avg_power, r.normalized_power,
+		       r.avg_temperature, r.max_sensor_speed_kmh, r.max_sustained_grade,
+		       r.start_time, r.end_time, r.like_count, r.save_count,
 		       r.filename, r.file_size, r.created_at, r.updated_at,
 		       ST_AsGeoJSON(ST_Force2D(center_point)) as center_point_geojson,
-		       ST_AsGeoJSON(ST_Force2D(simplified_path)) as simplified_path_geojson,
-		       route_length_km,
+		       ST_AsGeoJSON(ST_Force2D(` + simplifiedPathColumn(level) + `)) as simplified_path_geojson,
+		       route_length_km, route_length_3d_km,
 		       ST_AsGeoJSON(ST_Force2D(bounding_box)) as bounding_box_geojson,
 		       u.id, u.email, u.name, u.created_at, u.is_active, u.email_verified, u.last_login
 		FROM routes r
 		JOIN users u ON r.user_id = u.id
 		WHERE u.is_active = true
 		  AND r.center_point IS NOT NULL
-		  AND ST_Within(r.center_point, ST_MakeEnvelope($1, $2, $3, $4, 4326))
-		LIMIT $5 OFFSET $6
-	`
+		  AND ` + boundsSQL + filterSQL + fmt.Sprintf(" LIMIT $%d OFFSET $%d", 5+len(filterArgs), 6+len(filterArgs))
 
 	offset := (pagination.Page - 1) * pagination.Limit
 	
-	args := []interface{}{bounds.MinLng, bounds.MinLat, bounds.MaxLng, bounds.MaxLat, pagination.Limit, offset}
+	args := []interface{}{bounds.MinLng, bounds.MinLat, bounds.MaxLng, bounds.MaxLat}
+	args = append(args, filterArgs...)
+	args = append(args, pagination.Limit, offset)
 
-	log.Printf("INFO: Searching routes in bounds: lat[%.6f, %.6f], lng[%.6f, %.6f], page=%d, limit=%d", 
-		bounds.MinLat, bounds.MaxLat, bounds.MinLng, bounds.MaxLng, pagination.Page, pagination.Limit)
+	log.Printf("INFO: Searching routes in bounds: lat[%.6f, %.6f], lng[%.6f, %.6f], match=%s, page=%d, limit=%d", 
+		bounds.MinLat, bounds.MaxLat, bounds.MinLng, bounds.MaxLng, match, pagination.Page, pagination.Limit)
 
 	ctx := context.Background()
 	rows, err := h.db.Query(ctx, query, args...)
@@ -187,12 +306,17 @@ func (h *SpatialRouteHandler) GetRoutesInBounds(c *gin.Context) {
 		err := rows.Scan(
 			&route.ID, &route.UserID, &route.Name, &route.Difficulty,
 			&route.SceneryDescription, &route.AdditionalNotes,
-			&route.MaxElevationGain, &route.EstimatedDuration,
-			&route.AverageSpeed, &route.StartTime, &route.EndTime,
+			&route.MaxElevationGain, &route.TotalAscent, &route.TotalDescent,
+			&route.MinElevation, &route.MaxElevation, &route.EstimatedDuration,
+			&route.AverageSpeed, &route.MovingTime, &route.StoppedTime, &route.ElapsedTime,
+			&route.MovingAverageSpeed, &route.MaxSpeed,
+			&route.AvgHeartRate, &route.MaxHeartRate, &route.AvgCadence, &route.AvgPower, &route.NormalizedPower,
+			&route.AvgTemperature, &route.MaxSensorSpeed, &route.MaxSustainedGrade,
+			&route.StartTime, &route.EndTime,
 			&route.LikeCount, &route.SaveCount,
 			&route.Filename, &route.FileSize, &route.CreatedAt, &route.UpdatedAt,
 			&centerPointGeoJSON, &simplifiedPathGeoJSON,
-			&route.RouteLength, &boundingBoxGeoJSON,
+			&route.RouteLength, &route.RouteLength3D, &boundingBoxGeoJSON,
 			&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.IsActive, &user.EmailVerified, &user.LastLogin,
 		)
 		if err != nil {
@@ -220,10 +344,10 @@ func (h *SpatialRouteHandler) GetRoutesInBounds(c *gin.Context) {
 		JOIN users u ON r.user_id = u.id
 		WHERE u.is_active = true
 		  AND r.center_point IS NOT NULL
-		  AND ST_Within(r.center_point, ST_MakeEnvelope($1, $2, $3, $4, 4326))
-	`
+		  AND ` + boundsSQL + filterSQL
 	
 	countArgs := []interface{}{bounds.MinLng, bounds.MinLat, bounds.MaxLng, bounds.MaxLat}
+	countArgs = append(countArgs, filterArgs...)
 
 	var totalCount int
 	err = h.db.QueryRow(ctx, countQuery, countArgs...).Scan(&totalCount)
@@ -241,7 +365,9 @@ func (h *SpatialRouteHandler) GetRoutesInBounds(c *gin.Context) {
 		len(routes), pagination.Page, pagination.Limit, totalCount)
 	
 	c.JSON(http.StatusOK, gin.H{
-		"routes": routes,
+		"routes":         routes,
+		"simplification": level,
+		"match":          match,
 		"bounds": gin.H{
 			"min_lat": bounds.MinLat,
 			"max_lat": bounds.MaxLat,
@@ -0,0 +1,24 @@
+-- Add cumulative elevation statistics to routes table
+-- Migration: 011_add_cumulative_elevation_to_routes.sql
+
+BEGIN;
+
+-- Total ascent/descent are accumulated over smoothed elevations with a hysteresis threshold,
+-- unlike max_elevation_gain which is simply max elevation minus min elevation
+ALTER TABLE routes ADD COLUMN total_ascent DECIMAL(8,2) CHECK (total_ascent >= 0);
+ALTER TABLE routes ADD COLUMN total_descent DECIMAL(8,2) CHECK (total_descent >= 0);
+
+-- Add raw elevation extremes extracted from GPX
+ALTER TABLE routes ADD COLUMN min_elevation DECIMAL(8,2);
+ALTER TABLE routes ADD COLUMN max_elevation DECIMAL(8,2);
+
+-- Add index for filtering/sorting by climbing effort
+CREATE INDEX idx_routes_total_ascent ON routes(total_ascent);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.total_ascent IS 'Cumulative elevation gain in meters, calculated from smoothed GPX elevations with hysteresis';
+COMMENT ON COLUMN routes.total_descent IS 'Cumulative elevation loss in meters, calculated from smoothed GPX elevations with hysteresis';
+COMMENT ON COLUMN routes.min_elevation IS 'Minimum elevation in meters extracted from GPX';
+COMMENT ON COLUMN routes.max_elevation IS 'Maximum elevation in meters extracted from GPX';
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- Add moving/stopped time and speed statistics to routes table
+-- Migration: 012_add_moving_time_to_routes.sql
+
+BEGIN;
+
+-- Add time breakdown fields (seconds) - calculated from GPX timestamps with stop detection
+ALTER TABLE routes ADD COLUMN moving_time_seconds INTEGER CHECK (moving_time_seconds >= 0);
+ALTER TABLE routes ADD COLUMN stopped_time_seconds INTEGER CHECK (stopped_time_seconds >= 0);
+ALTER TABLE routes ADD COLUMN elapsed_time_seconds INTEGER CHECK (elapsed_time_seconds >= 0);
+
+-- Add speed fields (km/h) - moving average excludes detected stops
+ALTER TABLE routes ADD COLUMN moving_average_speed DECIMAL(8,2) CHECK (moving_average_speed >= 0);
+ALTER TABLE routes ADD COLUMN max_speed DECIMAL(8,2) CHECK (max_speed >= 0);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.moving_time_seconds IS 'Time spent moving in seconds, excluding detected stops';
+COMMENT ON COLUMN routes.stopped_time_seconds IS 'Time spent stopped in seconds, detected from point speed and time gaps';
+COMMENT ON COLUMN routes.elapsed_time_seconds IS 'Total elapsed time in seconds from first to last GPX timestamp';
+COMMENT ON COLUMN routes.moving_average_speed IS 'Average speed over moving time only in km/h';
+COMMENT ON COLUMN routes.max_speed IS 'Maximum speed in km/h, estimated over a short time window to suppress GPS jitter';
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Add sensor summary statistics to routes table
+-- Migration: 013_add_sensor_summary_to_routes.sql
+
+BEGIN;
+
+-- Add heart rate fields (bpm) - parsed from Garmin TrackPointExtension and similar GPX extensions
+ALTER TABLE routes ADD COLUMN avg_heart_rate DECIMAL(5,1) CHECK (avg_heart_rate >= 0);
+ALTER TABLE routes ADD COLUMN max_heart_rate DECIMAL(5,1) CHECK (max_heart_rate >= 0);
+
+-- Add cadence field (rpm for cycling, spm for running)
+ALTER TABLE routes ADD COLUMN avg_cadence DECIMAL(5,1) CHECK (avg_cadence >= 0);
+
+-- Add power fields (watts)
+ALTER TABLE routes ADD COLUMN avg_power DECIMAL(6,1) CHECK (avg_power >= 0);
+ALTER TABLE routes ADD COLUMN normalized_power DECIMAL(6,1) CHECK (normalized_power >= 0);
+
+-- Add indexes for filtering routes by effort
+CREATE INDEX idx_routes_avg_heart_rate ON routes(avg_heart_rate);
+CREATE INDEX idx_routes_avg_power ON routes(avg_power);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.avg_heart_rate IS 'Average heart rate in bpm from GPX sensor extensions';
+COMMENT ON COLUMN routes.max_heart_rate IS 'Maximum heart rate in bpm from GPX sensor extensions';
+COMMENT ON COLUMN routes.avg_cadence IS 'Average non-zero cadence from GPX sensor extensions';
+COMMENT ON COLUMN routes.avg_power IS 'Average power in watts from GPX sensor extensions';
+COMMENT ON COLUMN routes.normalized_power IS 'Normalized power in watts (30s rolling average, fourth-power mean)';
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Track the format of the originally uploaded activity file
+-- Migration: 014_add_source_format_to_routes.sql
+
+BEGIN;
+
+-- Add source format field - existing routes were all uploaded as GPX
+ALTER TABLE routes ADD COLUMN source_format VARCHAR(10) NOT NULL DEFAULT 'gpx';
+
+-- Add object key of the original upload when it was converted to GPX (NULL for GPX uploads)
+ALTER TABLE routes ADD COLUMN source_object_key VARCHAR(500);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.source_format IS 'Format of the originally uploaded file (gpx, fit)';
+COMMENT ON COLUMN routes.source_object_key IS 'R2 object key of the original upload when r2_object_key holds a generated GPX';
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- Document the additional source formats accepted on upload
+-- Migration: 015_update_source_format_comment.sql
+
+BEGIN;
+
+COMMENT ON COLUMN routes.source_format IS 'Format of the originally uploaded file (gpx, fit, tcx, kml, kmz), detected from its content';
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- Keep every track and segment of a route instead of only the first LineString
+-- Migration: 016_store_multilinestring_geometry.sql
+
+BEGIN;
+
+-- Store the original geometry as a MultiLineString with one part per track segment
+ALTER TABLE routes ALTER COLUMN original_geometry TYPE geometry(MultiLineStringZ,4326)
+    USING ST_Multi(ST_Force3D(original_geometry));
+
+-- The simplified path is derived from the full geometry and keeps segment boundaries as well
+ALTER TABLE routes ALTER COLUMN simplified_path TYPE geometry(MultiLineStringZ,4326)
+    USING ST_Multi(ST_Force3D(simplified_path));
+
+-- Add per-segment breakdown (distance, elevation, timing) in geometry order
+ALTER TABLE routes ADD COLUMN segments JSONB;
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.original_geometry IS 'Original route geometry from GPX file as a MultiLineString with one part per track segment (supports 3D with elevation)';
+COMMENT ON COLUMN routes.simplified_path IS 'Simplified route path for web display, one part per track segment';
+COMMENT ON COLUMN routes.segments IS 'Per-segment breakdown (distance, ascent/descent, timing) aligned with the parts of original_geometry';
+
+COMMIT;
@@ -0,0 +1,41 @@
+-- Store waypoints (water sources, huts, hazards, ...) as first-class points of interest of a route
+-- Migration: 017_create_route_waypoints_table.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS route_waypoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    route_id UUID NOT NULL,
+
+    -- Waypoint metadata
+    position INTEGER NOT NULL DEFAULT 0 CHECK (position >= 0),
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    symbol VARCHAR(100),
+    description TEXT,
+
+    -- Location
+    location geometry(Point,4326) NOT NULL,
+    elevation DECIMAL(8,2), -- in meters
+    recorded_at TIMESTAMP WITH TIME ZONE,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- Foreign key constraints
+    CONSTRAINT fk_route_waypoint_route_id FOREIGN KEY (route_id) REFERENCES routes(id) ON DELETE CASCADE
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_route_waypoints_route_id ON route_waypoints(route_id, position);
+CREATE INDEX idx_route_waypoints_location ON route_waypoints USING GIST(location);
+
+-- Add comments for documentation
+COMMENT ON TABLE route_waypoints IS 'Points of interest of a route, imported from GPX <wpt> elements or added by the route owner';
+COMMENT ON COLUMN route_waypoints.position IS 'Display order of the waypoint within its route';
+COMMENT ON COLUMN route_waypoints.symbol IS 'GPX symbol name (e.g. Drinking Water, Lodge, Danger Area)';
+COMMENT ON COLUMN route_waypoints.location IS 'Waypoint position (longitude/latitude, WGS84)';
+COMMENT ON COLUMN route_waypoints.elevation IS 'Waypoint elevation in meters';
+COMMENT ON COLUMN route_waypoints.recorded_at IS 'Waypoint timestamp from the uploaded file';
+
+COMMIT;
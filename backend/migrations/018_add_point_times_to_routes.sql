@@ -0,0 +1,12 @@
+-- Keep the timestamp of every point of the original geometry for elevation and speed profiles
+-- Migration: 018_add_point_times_to_routes.sql
+
+BEGIN;
+
+-- Add point timestamps - one entry per coordinate of original_geometry (in order), NULL where the file has no time
+ALTER TABLE routes ADD COLUMN point_times TIMESTAMP WITH TIME ZONE[];
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.point_times IS 'Timestamp of every point of original_geometry in order (NULL entries for points without time); NULL for routes without timing data';
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- Keep the heart rate of every point of the original geometry for splits and lap analysis
+-- Migration: 019_add_point_heart_rates_to_routes.sql
+
+BEGIN;
+
+-- Add point heart rates - one entry per coordinate of original_geometry (in order), NULL where the file has no reading
+ALTER TABLE routes ADD COLUMN point_heart_rates DOUBLE PRECISION[];
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.point_heart_rates IS 'Heart rate in bpm of every point of original_geometry in order (NULL entries for points without a reading); NULL for routes without heart rate data';
+
+COMMIT;
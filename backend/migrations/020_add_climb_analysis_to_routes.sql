@@ -0,0 +1,19 @@
+-- Store detected climbs and gradient distribution so routes can be chosen by steepness
+-- Migration: 020_add_climb_analysis_to_routes.sql
+
+BEGIN;
+
+-- Add climb analysis fields - calculated from the elevation profile on upload
+ALTER TABLE routes ADD COLUMN climbs JSONB;
+ALTER TABLE routes ADD COLUMN gradient_histogram JSONB;
+ALTER TABLE routes ADD COLUMN max_sustained_grade DECIMAL(5,2);
+
+-- Create index for filtering public listings by steepness
+CREATE INDEX idx_routes_max_sustained_grade ON routes(max_sustained_grade);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.climbs IS 'Significant climbs in route order: start/end distance, length, gain, average and max grade, score and category';
+COMMENT ON COLUMN routes.gradient_histogram IS 'Distance (and time when available) spent in each grade range';
+COMMENT ON COLUMN routes.max_sustained_grade IS 'Steepest average grade over 1 km in percent, NULL without elevation data or for shorter routes';
+
+COMMIT;
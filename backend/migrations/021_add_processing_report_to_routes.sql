@@ -0,0 +1,12 @@
+-- Record how GPS points were cleaned before route features were computed
+-- Migration: 021_add_processing_report_to_routes.sql
+
+BEGIN;
+
+-- Add processing report - written on upload, the stored GPX file is never modified
+ALTER TABLE routes ADD COLUMN processing_report JSONB;
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.processing_report IS 'Cleaning options, counts of removed or corrected points and raw vs cleaned metrics, NULL for routes uploaded before cleaning';
+
+COMMIT;
@@ -0,0 +1,42 @@
+-- Measure routes on the spheroid instead of in Web Mercator, and add the 3D slope distance
+-- Migration: 022_use_geodesic_route_length.sql
+
+BEGIN;
+
+-- Add 3D slope distance - geodesic leg lengths combined with the elevation change
+ALTER TABLE routes ADD COLUMN route_length_3d_km DECIMAL(10,3);
+
+-- One-shot backfill: recompute the lengths of existing routes from their stored geometry.
+-- Web Mercator lengths were inflated by 1/cos(latitude), so the speeds derived from them are recomputed too.
+WITH legs AS (
+    SELECT r.id,
+           dp.geom as point,
+           LAG(dp.geom) OVER (PARTITION BY r.id, dp.path[1] ORDER BY dp.path[2]) as prev
+    FROM routes r, ST_DumpPoints(ST_Multi(r.original_geometry)) dp
+    WHERE r.original_geometry IS NOT NULL
+),
+lengths AS (
+    SELECT id,
+           COALESCE(SUM(sqrt(power(ST_Distance(prev::geography, point::geography), 2)
+                             + power(ST_Z(point) - ST_Z(prev), 2))), 0) / 1000.0 as length_3d_km
+    FROM legs
+    WHERE prev IS NOT NULL
+    GROUP BY id
+)
+UPDATE routes r SET
+    route_length_km = ST_Length(ST_Force2D(r.original_geometry)::geography) / 1000.0,
+    route_length_3d_km = l.length_3d_km
+FROM lengths l
+WHERE r.id = l.id;
+
+UPDATE routes SET
+    average_speed = route_length_km / (estimated_duration / 60.0)
+WHERE route_length_km IS NOT NULL
+  AND estimated_duration > 0
+  AND average_speed IS NOT NULL;
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.route_length_km IS 'Geodesic route length in kilometers summed over all segments (PostGIS ST_Length on geography)';
+COMMENT ON COLUMN routes.route_length_3d_km IS 'Slope distance in kilometers including elevation change, equal to route_length_km without elevation data';
+
+COMMIT;
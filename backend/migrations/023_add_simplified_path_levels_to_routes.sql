@@ -0,0 +1,36 @@
+-- Store several levels of detail of the route path so maps can load geometry matching their zoom
+-- Migration: 023_add_simplified_path_levels_to_routes.sql
+
+BEGIN;
+
+-- Add simplified paths - topology-preserving simplification in Web Mercator, tolerance in ground meters
+ALTER TABLE routes ADD COLUMN simplified_path_2m geometry(MultiLineStringZ,4326);
+ALTER TABLE routes ADD COLUMN simplified_path_10m geometry(MultiLineStringZ,4326);
+ALTER TABLE routes ADD COLUMN simplified_path_50m geometry(MultiLineStringZ,4326);
+ALTER TABLE routes ADD COLUMN simplified_path_250m geometry(MultiLineStringZ,4326);
+ALTER TABLE routes ADD COLUMN simplified_path_1000m geometry(MultiLineStringZ,4326);
+
+-- Backfill existing routes; one Web Mercator unit is cos(latitude) meters on the ground,
+-- so the tolerances in meters are scaled by 1/cos(latitude)
+WITH scales AS (
+    SELECT id, 1.0 / GREATEST(cos(radians(ST_Y(ST_Centroid(original_geometry)))), 0.01) as scale
+    FROM routes
+    WHERE original_geometry IS NOT NULL
+)
+UPDATE routes r SET
+    simplified_path_2m = ST_Multi(ST_Force3D(ST_Transform(ST_SimplifyPreserveTopology(ST_Transform(r.original_geometry, 3857), 2 * s.scale), 4326))),
+    simplified_path_10m = ST_Multi(ST_Force3D(ST_Transform(ST_SimplifyPreserveTopology(ST_Transform(r.original_geometry, 3857), 10 * s.scale), 4326))),
+    simplified_path_50m = ST_Multi(ST_Force3D(ST_Transform(ST_SimplifyPreserveTopology(ST_Transform(r.original_geometry, 3857), 50 * s.scale), 4326))),
+    simplified_path_250m = ST_Multi(ST_Force3D(ST_Transform(ST_SimplifyPreserveTopology(ST_Transform(r.original_geometry, 3857), 250 * s.scale), 4326))),
+    simplified_path_1000m = ST_Multi(ST_Force3D(ST_Transform(ST_SimplifyPreserveTopology(ST_Transform(r.original_geometry, 3857), 1000 * s.scale), 4326)))
+FROM scales s
+WHERE r.id = s.id;
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.simplified_path_2m IS 'Route path simplified with a 2 m tolerance, for street-level zooms';
+COMMENT ON COLUMN routes.simplified_path_10m IS 'Route path simplified with a 10 m tolerance';
+COMMENT ON COLUMN routes.simplified_path_50m IS 'Route path simplified with a 50 m tolerance';
+COMMENT ON COLUMN routes.simplified_path_250m IS 'Route path simplified with a 250 m tolerance';
+COMMENT ON COLUMN routes.simplified_path_1000m IS 'Route path simplified with a 1 km tolerance, for country and continent views';
+
+COMMIT;
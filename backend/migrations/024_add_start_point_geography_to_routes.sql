@@ -0,0 +1,21 @@
+-- Support radius and nearest-neighbour searches on true distances
+-- Migration: 024_add_start_point_geography_to_routes.sql
+
+BEGIN;
+
+-- Add start point as geography so distances are measured on the spheroid
+ALTER TABLE routes ADD COLUMN start_point geography(Point,4326);
+
+-- Backfill from the first point of the stored geometry
+UPDATE routes
+SET start_point = ST_Force2D(ST_PointN(ST_GeometryN(original_geometry, 1), 1))::geography
+WHERE original_geometry IS NOT NULL;
+
+-- Create indexes for radius filters and KNN ordering (<->) on start points and full paths
+CREATE INDEX idx_routes_start_point ON routes USING GIST (start_point);
+CREATE INDEX idx_routes_original_geography ON routes USING GIST ((original_geometry::geography));
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.start_point IS 'First point of the route as geography, used for nearby route searches';
+
+COMMIT;
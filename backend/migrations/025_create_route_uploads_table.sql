@@ -0,0 +1,39 @@
+-- Track direct-to-storage uploads between the presigned PUT and the creation of the route
+-- Migration: 025_create_route_uploads_table.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS route_uploads (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+
+    -- Uploaded file
+    filename VARCHAR(255) NOT NULL,
+    object_key VARCHAR(500) NOT NULL,
+
+    -- Lifecycle
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    route_id UUID,
+    completed_at TIMESTAMP WITH TIME ZONE,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- Foreign key constraints
+    CONSTRAINT fk_route_upload_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_route_upload_route_id FOREIGN KEY (route_id) REFERENCES routes(id) ON DELETE SET NULL
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_route_uploads_user_id ON route_uploads(user_id);
+CREATE INDEX idx_route_uploads_pending ON route_uploads(expires_at) WHERE completed_at IS NULL;
+
+-- Add comments for documentation
+COMMENT ON TABLE route_uploads IS 'Files uploaded directly to storage with a presigned URL, pending or turned into a route';
+COMMENT ON COLUMN route_uploads.filename IS 'Original file name given when the upload was requested';
+COMMENT ON COLUMN route_uploads.object_key IS 'Staging object key the client uploads the file to';
+COMMENT ON COLUMN route_uploads.expires_at IS 'Time after which the upload URL can no longer be used';
+COMMENT ON COLUMN route_uploads.route_id IS 'Route created from the upload once it is completed';
+COMMENT ON COLUMN route_uploads.completed_at IS 'Time the upload was turned into a route';
+
+COMMIT;
@@ -0,0 +1,46 @@
+-- Track resumable (tus) uploads received in chunks until the route is created
+-- Migration: 026_create_route_tus_uploads_table.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS route_tus_uploads (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+
+    -- Upload state
+    filename VARCHAR(255) NOT NULL,
+    route_metadata JSONB NOT NULL,
+    upload_length BIGINT NOT NULL CHECK (upload_length > 0),
+    upload_offset BIGINT NOT NULL DEFAULT 0 CHECK (upload_offset >= 0 AND upload_offset <= upload_length),
+    chunk_offsets BIGINT[] NOT NULL DEFAULT '{}',
+
+    -- Lifecycle
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    route_id UUID,
+    completed_at TIMESTAMP WITH TIME ZONE,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    -- Foreign key constraints
+    CONSTRAINT fk_route_tus_upload_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_route_tus_upload_route_id FOREIGN KEY (route_id) REFERENCES routes(id) ON DELETE SET NULL
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_route_tus_uploads_user_id ON route_tus_uploads(user_id);
+CREATE INDEX idx_route_tus_uploads_pending ON route_tus_uploads(expires_at) WHERE completed_at IS NULL;
+
+-- Add comments for documentation
+COMMENT ON TABLE route_tus_uploads IS 'Resumable uploads (tus protocol), stored as one object per received chunk until completed';
+COMMENT ON COLUMN route_tus_uploads.filename IS 'Original file name from the Upload-Metadata header';
+COMMENT ON COLUMN route_tus_uploads.route_metadata IS 'Route name, difficulty and notes from the Upload-Metadata header, used to create the route';
+COMMENT ON COLUMN route_tus_uploads.upload_length IS 'Total file size in bytes (Upload-Length)';
+COMMENT ON COLUMN route_tus_uploads.upload_offset IS 'Number of bytes received so far (Upload-Offset)';
+COMMENT ON COLUMN route_tus_uploads.chunk_offsets IS 'Start offset of every stored chunk, in upload order';
+COMMENT ON COLUMN route_tus_uploads.expires_at IS 'Time after which an incomplete upload and its chunks are deleted';
+COMMENT ON COLUMN route_tus_uploads.route_id IS 'Route created once all bytes were received';
+COMMENT ON COLUMN route_tus_uploads.completed_at IS 'Time the upload was turned into a route';
+
+COMMIT;
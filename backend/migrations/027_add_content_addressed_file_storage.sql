@@ -0,0 +1,30 @@
+-- Deduplicate uploaded files: hash every upload and share identical stored objects between routes
+-- Migration: 027_add_content_addressed_file_storage.sql
+
+BEGIN;
+
+-- Add the SHA-256 of the uploaded file; existing routes keep NULL as their files were never hashed
+ALTER TABLE routes ADD COLUMN file_sha256 CHAR(64);
+
+-- A user can upload the same file only once
+CREATE UNIQUE INDEX idx_routes_user_file_sha256 ON routes(user_id, file_sha256) WHERE file_sha256 IS NOT NULL;
+
+-- Reference counts of content-addressed objects shared by routes
+CREATE TABLE IF NOT EXISTS storage_blobs (
+    object_key VARCHAR(500) PRIMARY KEY,
+    ref_count INTEGER NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    file_size BIGINT NOT NULL,
+
+    -- Timestamps
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.file_sha256 IS 'Hex SHA-256 of the uploaded file, used to detect repeated uploads';
+COMMENT ON TABLE storage_blobs IS 'Content-addressed stored objects (blobs/sha256/...) and the number of routes referencing them';
+COMMENT ON COLUMN storage_blobs.object_key IS 'Object key derived from the SHA-256 of the content';
+COMMENT ON COLUMN storage_blobs.ref_count IS 'Number of route files (r2_object_key or source_object_key) referencing the object';
+COMMENT ON COLUMN storage_blobs.file_size IS 'Object size in bytes';
+
+COMMIT;
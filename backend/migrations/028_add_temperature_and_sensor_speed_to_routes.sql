@@ -0,0 +1,16 @@
+-- Add temperature and sensor speed summaries to routes table
+-- Migration: 028_add_temperature_and_sensor_speed_to_routes.sql
+
+BEGIN;
+
+-- Add temperature field (degrees Celsius) - parsed from Garmin TrackPointExtension atemp
+ALTER TABLE routes ADD COLUMN avg_temperature DECIMAL(4,1);
+
+-- Add sensor speed field (km/h) - parsed from speed sensor extensions, independent of GPS speed
+ALTER TABLE routes ADD COLUMN max_sensor_speed_kmh DECIMAL(6,2) CHECK (max_sensor_speed_kmh >= 0);
+
+-- Add comments for documentation
+COMMENT ON COLUMN routes.avg_temperature IS 'Average ambient temperature in degrees Celsius from GPX sensor extensions';
+COMMENT ON COLUMN routes.max_sensor_speed_kmh IS 'Maximum speed in km/h reported by a speed sensor in GPX extensions';
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- Give direct uploads a completion deadline separate from the upload URL expiry
+-- Migration: 029_add_completion_deadline_to_route_uploads.sql
+
+BEGIN;
+
+-- A PUT started just before the URL expires can finish after it, so completion is allowed until this later deadline
+ALTER TABLE route_uploads ADD COLUMN complete_by TIMESTAMP WITH TIME ZONE;
+UPDATE route_uploads SET complete_by = expires_at + INTERVAL '10 minutes';
+ALTER TABLE route_uploads ALTER COLUMN complete_by SET NOT NULL;
+
+-- Pending uploads are now expired by their completion deadline
+DROP INDEX IF EXISTS idx_route_uploads_pending;
+CREATE INDEX idx_route_uploads_pending ON route_uploads(complete_by) WHERE completed_at IS NULL;
+CREATE INDEX idx_route_uploads_completed ON route_uploads(completed_at) WHERE completed_at IS NOT NULL;
+
+-- Add comments for documentation
+COMMENT ON COLUMN route_uploads.complete_by IS 'Time after which the upload can no longer be completed and its file is deleted';
+COMMENT ON COLUMN route_uploads.completed_at IS 'Time the upload was turned into a route; the row is deleted some time after';
+
+COMMIT;
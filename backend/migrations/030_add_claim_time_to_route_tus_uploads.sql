@@ -0,0 +1,20 @@
+-- Let claims of resumable uploads expire and purge completed uploads
+-- Migration: 030_add_claim_time_to_route_tus_uploads.sql
+
+BEGIN;
+
+-- The route is created under a claim; a claim whose request died is taken over or cleaned up after a timeout
+ALTER TABLE route_tus_uploads ADD COLUMN claimed_at TIMESTAMP WITH TIME ZONE;
+
+-- completed_at used to mark claims as well, so uploads claimed without a route are still being completed
+UPDATE route_tus_uploads SET claimed_at = completed_at, completed_at = NULL
+WHERE completed_at IS NOT NULL AND route_id IS NULL;
+
+-- Completed uploads are deleted after a retention period
+CREATE INDEX idx_route_tus_uploads_completed ON route_tus_uploads(completed_at) WHERE completed_at IS NOT NULL;
+
+-- Add comments for documentation
+COMMENT ON COLUMN route_tus_uploads.claimed_at IS 'Time a request started creating the route from the upload; cleared when it fails';
+COMMENT ON COLUMN route_tus_uploads.completed_at IS 'Time the upload was turned into a route; the row is deleted some time after';
+
+COMMIT;
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gpxbase/backend/utils"
 )
 
 // DifficultyLevel represents the difficulty level of a route
@@ -27,8 +28,25 @@ type Route struct {
 	SceneryDescription string          `json:"scenery_description,omitempty" db:"scenery_description"`
 	AdditionalNotes    string          `json:"additional_notes,omitempty" db:"additional_notes"`
 	MaxElevationGain   float64         `json:"max_elevation_gain" db:"max_elevation_gain"`   // in meters
+	TotalAscent        *float64        `json:"total_ascent,omitempty" db:"total_ascent"`     // calculated from GPX in meters
+	TotalDescent       *float64        `json:"total_descent,omitempty" db:"total_descent"`   // calculated from GPX in meters
+	MinElevation       *float64        `json:"min_elevation,omitempty" db:"min_elevation"`   // extracted from GPX in meters
+	MaxElevation       *float64        `json:"max_elevation,omitempty" db:"max_elevation"`   // extracted from GPX in meters
 	EstimatedDuration  *int            `json:"estimated_duration,omitempty" db:"estimated_duration"` // calculated from GPX in minutes
 	AverageSpeed       *float64        `json:"average_speed,omitempty" db:"average_speed"`   // calculated from GPX in km/h
+	MovingTime         *int            `json:"moving_time_seconds,omitempty" db:"moving_time_seconds"`   // calculated from GPX in seconds
+	StoppedTime        *int            `json:"stopped_time_seconds,omitempty" db:"stopped_time_seconds"` // calculated from GPX in seconds
+	ElapsedTime        *int            `json:"elapsed_time_seconds,omitempty" db:"elapsed_time_seconds"` // calculated from GPX in seconds
+	MovingAverageSpeed *float64        `json:"moving_average_speed,omitempty" db:"moving_average_speed"` // calculated from GPX in km/h, excluding stops
+	MaxSpeed           *float64        `json:"max_speed,omitempty" db:"max_speed"`           // calculated from GPX in km/h
+	AvgHeartRate       *float64        `json:"avg_heart_rate,omitempty" db:"avg_heart_rate"` // from GPX sensor extensions in bpm
+	MaxHeartRate       *float64        `json:"max_heart_rate,omitempty" db:"max_heart_rate"` // from GPX sensor extensions in bpm
+	AvgCadence         *float64        `json:"avg_cadence,omitempty" db:"avg_cadence"`       // from GPX sensor extensions
+	AvgPower           *float64        `json:"avg_power,omitempty" db:"avg_power"`           // from GPX sensor extensions in watts
+	NormalizedPower    *float64        `json:"normalized_power,omitempty" db:"normalized_power"` // from GPX sensor extensions in watts
+	AvgTemperature     *float64        `json:"avg_temperature,omitempty" db:"avg_temperature"` // from GPX sensor extensions in degrees Celsius
+	MaxSensorSpeed     *float64        `json:"max_sensor_speed_kmh,omitempty" db:"max_sensor_speed_kmh"` // from GPX speed sensor extensions in km/h
+	MaxSustainedGrade  *float64        `json:"max_sustained_grade,omitempty" db:"max_sustained_grade"` // steepest 1 km average grade in percent
 	StartTime          *time.Time      `json:"start_time,omitempty" db:"start_time"`         // extracted from GPX
 	EndTime            *time.Time      `json:"end_time,omitempty" db:"end_time"`             // extracted from GPX
 	
@@ -40,14 +58,23 @@ type Route struct {
 	Filename           string          `json:"filename" db:"filename"`
 	R2ObjectKey        string          `json:"r2_object_key" db:"r2_object_key"`
 	FileSize           int64           `json:"file_size" db:"file_size"`
+	SourceFormat       string          `json:"source_format" db:"source_format"`         // format of the uploaded file (gpx, fit, tcx, kml, kmz)
+	SourceObjectKey    *string         `json:"-" db:"source_object_key"`                 // original upload when R2ObjectKey holds a generated GPX
+	FileSHA256         *string         `json:"file_sha256,omitempty" db:"file_sha256"`   // SHA-256 of the uploaded file, identifies repeated uploads
 	
 	// Geographical features
 	CenterPoint        *string         `json:"center_point,omitempty" db:"center_point"`        // WKT format point
 	ConvexHull         *string         `json:"convex_hull,omitempty" db:"convex_hull"`          // WKT format polygon  
-	SimplifiedPath     *string         `json:"simplified_path,omitempty" db:"simplified_path"`  // WKT format linestring
-	RouteLength        *float64        `json:"route_length_km,omitempty" db:"route_length_km"`  // Calculated route length in km
+	SimplifiedPath     *string         `json:"simplified_path,omitempty" db:"simplified_path"`  // WKT format multilinestring
+	RouteLength        *float64        `json:"route_length_km,omitempty" db:"route_length_km"`  // Calculated geodesic route length in km
+	RouteLength3D      *float64        `json:"route_length_3d_km,omitempty" db:"route_length_3d_km"` // Slope distance including elevation change in km
 	BoundingBox        *string         `json:"bounding_box,omitempty" db:"bounding_box"`        // WKT format bounding box polygon
 	OriginalGeometry   *string         `json:"-" db:"original_geometry"`                        // Original geometry in PostGIS format (cold storage)
+	Segments           []RouteSegment  `json:"segments,omitempty" db:"segments"`                // Per-segment breakdown of the route
+	Climbs             []RouteClimb    `json:"climbs,omitempty" db:"climbs"`                    // Significant climbs along the route
+	GradientHistogram  []GradeBucket   `json:"gradient_histogram,omitempty" db:"gradient_histogram"` // Distance and time per grade range
+	ProcessingReport   *ProcessingReport `json:"processing_report,omitempty" db:"processing_report"` // What GPS cleaning removed before features were computed
+	Waypoints          []RouteWaypoint `json:"waypoints,omitempty" db:"-"`                      // Points of interest, stored in route_waypoints
 	
 	// Timestamps
 	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
@@ -84,18 +111,37 @@ type RouteResponse struct {
 	SceneryDescription string          `json:"scenery_description,omitempty"`
 	AdditionalNotes    string          `json:"additional_notes,omitempty"`
 	MaxElevationGain   float64         `json:"max_elevation_gain"`
+	TotalAscent        *float64        `json:"total_ascent,omitempty"`
+	TotalDescent       *float64        `json:"total_descent,omitempty"`
+	MinElevation       *float64        `json:"min_elevation,omitempty"`
+	MaxElevation       *float64        `json:"max_elevation,omitempty"`
 	EstimatedDuration  *int            `json:"estimated_duration,omitempty"`
 	AverageSpeed       *float64        `json:"average_speed,omitempty"`
+	MovingTime         *int            `json:"moving_time_seconds,omitempty"`
+	StoppedTime        *int            `json:"stopped_time_seconds,omitempty"`
+	ElapsedTime        *int            `json:"elapsed_time_seconds,omitempty"`
+	MovingAverageSpeed *float64        `json:"moving_average_speed,omitempty"`
+	MaxSpeed           *float64        `json:"max_speed,omitempty"`
+	AvgHeartRate       *float64        `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate       *float64        `json:"max_heart_rate,omitempty"`
+	AvgCadence         *float64        `json:"avg_cadence,omitempty"`
+	AvgPower           *float64        `json:"avg_power,omitempty"`
+	NormalizedPower    *float64        `json:"normalized_power,omitempty"`
+	AvgTemperature     *float64        `json:"avg_temperature,omitempty"`
+	MaxSensorSpeed     *float64        `json:"max_sensor_speed_kmh,omitempty"`
+	MaxSustainedGrade  *float64        `json:"max_sustained_grade,omitempty"`
 	StartTime          *time.Time      `json:"start_time,omitempty"`
 	EndTime            *time.Time      `json:"end_time,omitempty"`
 	LikeCount          int             `json:"like_count"`
 	SaveCount          int             `json:"save_count"`
 	Filename           string          `json:"filename"`
 	FileSize           int64           `json:"file_size"`
+	SourceFormat       string          `json:"source_format,omitempty"`
 	CenterPoint        *string         `json:"center_point,omitempty"`
 	ConvexHull         *string         `json:"convex_hull,omitempty"`
 	SimplifiedPath     *string         `json:"simplified_path,omitempty"`
 	RouteLength        *float64        `json:"route_length_km,omitempty"`
+	RouteLength3D      *float64        `json:"route_length_3d_km,omitempty"`
 	BoundingBox        *string         `json:"bounding_box,omitempty"`
 	CreatedAt          time.Time       `json:"created_at"`
 	UpdatedAt          time.Time       `json:"updated_at"`
@@ -104,16 +150,70 @@ type RouteResponse struct {
 // RouteDetailResponse represents a detailed route response with download URL
 type RouteDetailResponse struct {
 	RouteResponse
-	DownloadURL string `json:"download_url"`
-	ExpiresAt   string `json:"expires_at"`
+	Segments          []RouteSegment    `json:"segments,omitempty"`
+	Climbs            []RouteClimb      `json:"climbs,omitempty"`
+	GradientHistogram []GradeBucket     `json:"gradient_histogram,omitempty"`
+	ProcessingReport  *ProcessingReport `json:"processing_report,omitempty"`
+	Waypoints         []RouteWaypoint   `json:"waypoints"`
+	DownloadURL       string            `json:"download_url"`
+	ExpiresAt         string            `json:"expires_at"`
 }
 
+// RouteSegment is the breakdown of one track segment (or route) of a route, in geometry order
+type RouteSegment = utils.SegmentStats
+
+// RouteClimb is a significant climb along a route, in route order
+type RouteClimb struct {
+	Index           int     `json:"index"`
+	StartDistanceKm float64 `json:"start_distance_km"`
+	EndDistanceKm   float64 `json:"end_distance_km"`
+	LengthKm        float64 `json:"length_km"`
+	ElevationGain   float64 `json:"elevation_gain"`
+	StartElevation  float64 `json:"start_elevation"`
+	EndElevation    float64 `json:"end_elevation"`
+	AverageGrade    float64 `json:"average_grade"`
+	MaxGrade        float64 `json:"max_grade"`
+	Score           float64 `json:"score"`
+	Category        string  `json:"category"`
+}
+
+// GradeBucket is one bin of a route's gradient histogram (grades in percent, open-ended bins have no bound)
+type GradeBucket struct {
+	MinGrade    *float64 `json:"min_grade,omitempty"`
+	MaxGrade    *float64 `json:"max_grade,omitempty"`
+	DistanceKm  float64  `json:"distance_km"`
+	Percent     float64  `json:"percent"`
+	TimeSeconds *int     `json:"time_seconds,omitempty"`
+}
+
+// ProcessingReport records how the GPS points of a route were cleaned on upload; the stored file is unchanged
+type ProcessingReport = utils.CleaningReport
+
 // RouteWithUserResponse represents a route response that includes user information
 type RouteWithUserResponse struct {
 	RouteResponse
 	User UserPublicResponse `json:"user"`
 }
 
+// NearbyRouteResponse is a route found by a radius search, with its distance from the search point
+type NearbyRouteResponse struct {
+	RouteWithUserResponse
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// RouteCluster groups the routes whose center points fall into the same map grid cell
+type RouteCluster struct {
+	Count        int            `json:"count"`
+	Latitude     float64        `json:"latitude"` // centroid of the route center points
+	Longitude    float64        `json:"longitude"`
+	MinLat       float64        `json:"min_lat"` // extent of the route center points, to zoom into the cluster
+	MaxLat       float64        `json:"max_lat"`
+	MinLng       float64        `json:"min_lng"`
+	MaxLng       float64        `json:"max_lng"`
+	Difficulties map[string]int `json:"difficulties"`       // number of routes per difficulty level
+	RouteID      *uuid.UUID     `json:"route_id,omitempty"` // set when the cluster holds a single route
+}
+
 // ToResponse converts a Route to RouteResponse
 func (r *Route) ToResponse() RouteResponse {
 	return RouteResponse{
@@ -124,18 +224,37 @@ func (r *Route) ToResponse() RouteResponse {
 		SceneryDescription: r.SceneryDescription,
 		AdditionalNotes:    r.AdditionalNotes,
 		MaxElevationGain:   r.MaxElevationGain,
+		TotalAscent:        r.TotalAscent,
+		TotalDescent:       r.TotalDescent,
+		MinElevation:       r.MinElevation,
+		MaxElevation:       r.MaxElevation,
 		EstimatedDuration:  r.EstimatedDuration,
 		AverageSpeed:       r.AverageSpeed,
+		MovingTime:         r.MovingTime,
+		StoppedTime:        r.StoppedTime,
+		ElapsedTime:        r.ElapsedTime,
+		MovingAverageSpeed: r.MovingAverageSpeed,
+		MaxSpeed:           r.MaxSpeed,
+		AvgHeartRate:       r.AvgHeartRate,
+		MaxHeartRate:       r.MaxHeartRate,
+		AvgCadence:         r.AvgCadence,
+		AvgPower:           r.AvgPower,
+		NormalizedPower:    r.NormalizedPower,
+		AvgTemperature:     r.AvgTemperature,
+		MaxSensorSpeed:     r.MaxSensorSpeed,
+		MaxSustainedGrade:  r.MaxSustainedGrade,
 		StartTime:          r.StartTime,
 		EndTime:            r.EndTime,
 		LikeCount:          r.LikeCount,
 		SaveCount:          r.SaveCount,
 		Filename:           r.Filename,
 		FileSize:           r.FileSize,
+		SourceFormat:       r.SourceFormat,
 		CenterPoint:        r.CenterPoint,
 		ConvexHull:         r.ConvexHull,
 		SimplifiedPath:     r.SimplifiedPath,
 		RouteLength:        r.RouteLength,
+		RouteLength3D:      r.RouteLength3D,
 		BoundingBox:        r.BoundingBox,
 		CreatedAt:          r.CreatedAt,
 		UpdatedAt:          r.UpdatedAt,
@@ -145,9 +264,14 @@ func (r *Route) ToResponse() RouteResponse {
 // ToDetailResponse converts a Route to RouteDetailResponse with download URL
 func (r *Route) ToDetailResponse(downloadURL, expiresAt string) RouteDetailResponse {
 	return RouteDetailResponse{
-		RouteResponse: r.ToResponse(),
-		DownloadURL:   downloadURL,
-		ExpiresAt:     expiresAt,
+		RouteResponse:     r.ToResponse(),
+		Segments:          r.Segments,
+		Climbs:            r.Climbs,
+		GradientHistogram: r.GradientHistogram,
+		ProcessingReport:  r.ProcessingReport,
+		Waypoints:         r.Waypoints,
+		DownloadURL:       downloadURL,
+		ExpiresAt:         expiresAt,
 	}
 }
 
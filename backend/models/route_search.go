@@ -0,0 +1,19 @@
+package models
+
+import "encoding/json"
+
+// RouteGeometrySearchRequest represents a search for routes along a line or inside an area
+type RouteGeometrySearchRequest struct {
+	Geometry     json.RawMessage `json:"geometry" binding:"required"`                           // GeoJSON LineString, MultiLineString, Polygon or MultiPolygon
+	BufferMeters *float64        `json:"buffer_m,omitempty" binding:"omitempty,min=0,max=5000"` // corridor width on each side of a line
+	Match        string          `json:"match,omitempty" binding:"omitempty,oneof=intersects contains"`
+	Limit        int             `json:"limit,omitempty" binding:"omitempty,min=1,max=100"`
+}
+
+// RouteGeometryMatchResponse is a route found by a geometry search, with how much of it lies in the searched area
+type RouteGeometryMatchResponse struct {
+	RouteWithUserResponse
+	SharedLengthKm float64 `json:"shared_length_km"` // length of the route inside the area (or line corridor)
+	SharedPercent  float64 `json:"shared_percent"`   // share of the route length inside the area
+	Inside         bool    `json:"inside"`           // the whole route lies inside the area
+}
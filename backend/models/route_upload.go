@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RouteUploadRequest represents a request for a direct upload URL
+type RouteUploadRequest struct {
+	Filename string `json:"filename" binding:"required,max=255"`
+	FileSize int64  `json:"file_size,omitempty" binding:"min=0"` // announced size in bytes, checked against the upload limit
+}
+
+// RouteUploadResponse is a direct upload the client PUTs the activity file to before completing it
+type RouteUploadResponse struct {
+	UploadID    uuid.UUID `json:"upload_id"`
+	UploadURL   string    `json:"upload_url"`
+	Method      string    `json:"method"`
+	ExpiresAt   time.Time `json:"expires_at"`  // the PUT must start before this time
+	CompleteBy  time.Time `json:"complete_by"` // the upload must be completed before this time
+	MaxFileSize int64     `json:"max_file_size"`
+}
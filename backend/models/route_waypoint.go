@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RouteWaypoint represents a point of interest of a route (water source, hut, hazard, ...)
+type RouteWaypoint struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	RouteID     uuid.UUID  `json:"route_id" db:"route_id"`
+	Position    int        `json:"position" db:"position"`
+	Name        string     `json:"name" db:"name"`
+	Symbol      *string    `json:"symbol,omitempty" db:"symbol"` // GPX symbol name, e.g. "Drinking Water"
+	Description *string    `json:"description,omitempty" db:"description"`
+	Latitude    float64    `json:"latitude" db:"latitude"`
+	Longitude   float64    `json:"longitude" db:"longitude"`
+	Elevation   *float64   `json:"elevation,omitempty" db:"elevation"` // in meters
+	Time        *time.Time `json:"time,omitempty" db:"recorded_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// RouteWaypointCreateRequest represents the request payload for adding a waypoint to a route
+type RouteWaypointCreateRequest struct {
+	Name        string     `json:"name" binding:"required,max=255"`
+	Symbol      *string    `json:"symbol,omitempty" binding:"omitempty,max=100"`
+	Description *string    `json:"description,omitempty" binding:"omitempty,max=2000"`
+	Latitude    *float64   `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude   *float64   `json:"longitude" binding:"required,min=-180,max=180"`
+	Elevation   *float64   `json:"elevation,omitempty"`
+	Time        *time.Time `json:"time,omitempty"`
+}
+
+// RouteWaypointUpdateRequest represents the request payload for updating a waypoint
+type RouteWaypointUpdateRequest struct {
+	Name        *string    `json:"name,omitempty" binding:"omitempty,max=255"`
+	Symbol      *string    `json:"symbol,omitempty" binding:"omitempty,max=100"`
+	Description *string    `json:"description,omitempty" binding:"omitempty,max=2000"`
+	Latitude    *float64   `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64   `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	Elevation   *float64   `json:"elevation,omitempty"`
+	Time        *time.Time `json:"time,omitempty"`
+	Position    *int       `json:"position,omitempty" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gpxbase/backend/storage"
+)
+
+// BlobService stores content-addressed files once and counts the routes referencing them
+type BlobService struct {
+	db      *pgxpool.Pool
+	storage storage.FileStorage
+}
+
+// NewBlobService creates a new BlobService instance
+func NewBlobService(db *pgxpool.Pool, fileStorage storage.FileStorage) *BlobService {
+	return &BlobService{
+		db:      db,
+		storage: fileStorage,
+	}
+}
+
+// HashFile returns the hex SHA-256 of the content, read from the start
+func HashFile(content io.ReadSeeker) (string, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// maxBlobAcquireAttempts bounds how often AcquireBlob uploads again when a concurrent release deleted the object
+const maxBlobAcquireAttempts = 3
+
+// AcquireBlob adds a reference to the object stored under key, uploading the content when it is not stored yet.
+// The upload happens outside any transaction so slow storage never holds a row lock.
+func (bs *BlobService) AcquireBlob(ctx context.Context, key string, content io.ReadSeeker, size int64, contentType string) error {
+	var refCount int
+	err := bs.db.QueryRow(ctx, `SELECT ref_count FROM storage_blobs WHERE object_key = $1`, key).Scan(&refCount)
+	if err != nil && err.Error() != "no rows in result set" {
+		return fmt.Errorf("failed to look up blob %s: %w", key, err)
+	}
+	upload := err != nil
+
+	for attempt := 1; ; attempt++ {
+		// The key is derived from the content, so concurrent uploads of the same blob write identical bytes
+		if upload {
+			if _, err := content.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind file: %w", err)
+			}
+			if err := bs.storage.UploadFile(key, content, contentType); err != nil {
+				return err
+			}
+		}
+
+		var stored bool
+		refCount, stored, err = bs.referenceBlob(ctx, key, size)
+		if err != nil {
+			return err
+		}
+		if stored {
+			if upload {
+				log.Printf("INFO: Stored new blob %s (%d bytes)", key, size)
+			} else {
+				log.Printf("INFO: Reusing stored blob %s (%d references)", key, refCount)
+			}
+			return nil
+		}
+		if attempt == maxBlobAcquireAttempts {
+			return fmt.Errorf("blob %s was deleted while being stored", key)
+		}
+		upload = true
+	}
+}
+
+// referenceBlob increments the reference count of key in a short transaction. It reports false without
+// counting the reference when the object is missing, e.g. deleted by a concurrent release after the upload.
+func (bs *BlobService) referenceBlob(ctx context.Context, key string, size int64) (int, bool, error) {
+	tx, err := bs.db.Begin(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The row stays locked until commit, so a concurrent release cannot delete the object meanwhile
+	var refCount int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO storage_blobs (object_key, ref_count, file_size)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (object_key) DO UPDATE
+		SET ref_count = storage_blobs.ref_count + 1, updated_at = CURRENT_TIMESTAMP
+		RETURNING ref_count
+	`, key, size).Scan(&refCount)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to reference blob %s: %w", key, err)
+	}
+
+	exists, err := bs.storage.FileExists(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, fmt.Errorf("failed to commit blob reference %s: %w", key, err)
+	}
+	return refCount, true, nil
+}
+
+// ReleaseBlob removes a reference to the object stored under key and deletes the object with its last
+// reference. Files stored before deduplication have no reference count and are deleted directly.
+func (bs *BlobService) ReleaseBlob(ctx context.Context, key string) error {
+	tx, err := bs.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var refCount int
+	err = tx.QueryRow(ctx, `
+		UPDATE storage_blobs
+		SET ref_count = ref_count - 1, updated_at = CURRENT_TIMESTAMP
+		WHERE object_key = $1
+		RETURNING ref_count
+	`, key).Scan(&refCount)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return bs.storage.DeleteFile(key)
+		}
+		return fmt.Errorf("failed to release blob %s: %w", key, err)
+	}
+
+	if refCount > 0 {
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit blob release %s: %w", key, err)
+		}
+		return nil
+	}
+
+	// The object is deleted while the row is still locked: a concurrent upload of the same content waits in
+	// referenceBlob until commit, then finds the object gone and stores it again (see AcquireBlob). Should the
+	// commit fail after the delete, the next AcquireBlob notices the missing object the same way.
+	if _, err := tx.Exec(ctx, `DELETE FROM storage_blobs WHERE object_key = $1`, key); err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	if err := bs.storage.DeleteFile(key); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit blob release %s: %w", key, err)
+	}
+	log.Printf("INFO: Deleted blob %s with its last reference", key)
+	return nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"gpxbase/backend/utils"
+)
+
+const (
+	// ClimbSampleDistance is the spacing in meters at which the elevation profile is resampled for gradient analysis
+	ClimbSampleDistance = 50.0
+	// SustainedGradeDistance is the distance in meters over which the max sustained grade is averaged
+	SustainedGradeDistance = 1000.0
+	// MaxGradeDistance is the distance in meters over which the max grade of a climb is averaged
+	MaxGradeDistance = 100.0
+
+	// A climb must reach all of these to be reported
+	MinClimbLength = 500.0 // in meters
+	MinClimbGain   = 30.0  // in meters
+	MinClimbGrade  = 3.0   // average grade in percent
+
+	// ClimbDropTolerance is the descent in meters allowed inside a climb before it ends,
+	// raised to ClimbDropRatio of the gain reached so far on long climbs
+	ClimbDropTolerance = 10.0
+	ClimbDropRatio     = 0.2
+)
+
+// Climb is a significant ascent along a route
+type Climb struct {
+	Index           int     `json:"index"`
+	StartDistanceKm float64 `json:"start_distance_km"`
+	EndDistanceKm   float64 `json:"end_distance_km"`
+	LengthKm        float64 `json:"length_km"`
+	ElevationGain   float64 `json:"elevation_gain"` // from the lowest to the highest point, in meters
+	StartElevation  float64 `json:"start_elevation"`
+	EndElevation    float64 `json:"end_elevation"`
+	AverageGrade    float64 `json:"average_grade"` // in percent
+	MaxGrade        float64 `json:"max_grade"`     // steepest 100 m, in percent
+	Score           float64 `json:"score"`         // length in meters times average grade
+	Category        string  `json:"category"`      // HC, 1, 2, 3, 4 or empty for uncategorized climbs
+}
+
+// GradeBucket is one bin of the gradient histogram, bounds are in percent and nil when open-ended
+type GradeBucket struct {
+	MinGrade    *float64 `json:"min_grade,omitempty"`
+	MaxGrade    *float64 `json:"max_grade,omitempty"`
+	DistanceKm  float64  `json:"distance_km"`
+	Percent     float64  `json:"percent"` // share of the route distance
+	TimeSeconds *int     `json:"time_seconds,omitempty"`
+}
+
+// ClimbAnalysis holds the climbs and gradient distribution of a route
+type ClimbAnalysis struct {
+	Climbs            []Climb       `json:"climbs"`
+	GradientHistogram []GradeBucket `json:"gradient_histogram"`
+	MaxSustainedGrade *float64      `json:"max_sustained_grade"` // steepest 1 km average grade in percent, nil for shorter routes
+}
+
+// gradeBucketBounds are the upper bounds of the gradient histogram bins in percent; the last bin is open-ended
+var gradeBucketBounds = []float64{-10, -6, -3, -1, 1, 3, 6, 10, 15}
+
+// climbCategories are the minimum scores of each climb category, hardest first
+var climbCategories = []struct {
+	Name     string
+	MinScore float64
+}{
+	{"HC", 80000},
+	{"1", 64000},
+	{"2", 32000},
+	{"3", 16000},
+	{"4", 8000},
+}
+
+// profileSample is a point of the resampled elevation profile
+type profileSample struct {
+	Distance float64 // along the route, in meters
+	Ele      float64
+	Moving   float64 // cumulative time within segments, in seconds
+	HasTime  bool
+}
+
+// AnalyzeClimbs detects significant climbs and builds the gradient histogram from the route geometry and the
+// optional per-point timestamps. It returns nil when the route has no elevation data.
+func AnalyzeClimbs(lines [][][]float64, times []*time.Time) *ClimbAnalysis {
+	profiles := resampleElevationProfiles(lines, times)
+	if profiles == nil {
+		return nil
+	}
+
+	analysis := &ClimbAnalysis{Climbs: []Climb{}}
+	for _, profile := range profiles {
+		for _, climb := range detectClimbs(profile) {
+			climb.Index = len(analysis.Climbs)
+			analysis.Climbs = append(analysis.Climbs, climb)
+		}
+		if grade, ok := maxAverageGrade(profile, SustainedGradeDistance); ok {
+			if analysis.MaxSustainedGrade == nil || grade > *analysis.MaxSustainedGrade {
+				analysis.MaxSustainedGrade = &grade
+			}
+		}
+	}
+	analysis.GradientHistogram = gradientHistogram(profiles)
+
+	return analysis
+}
+
+// resampleElevationProfiles smooths the elevation of every geometry line and samples it every ClimbSampleDistance
+// meters. Lines are kept apart so the gap between two segments is never treated as a slope.
+func resampleElevationProfiles(lines [][][]float64, times []*time.Time) [][]profileSample {
+	count := 0
+	hasElevation := false
+	for _, line := range lines {
+		count += len(line)
+		for _, coord := range line {
+			// Geometries are stored in 3D, so routes without elevation data have Z = 0 everywhere
+			if len(coord) > 2 && coord[2] != 0 {
+				hasElevation = true
+			}
+		}
+	}
+	if !hasElevation {
+		return nil
+	}
+	if len(times) != count {
+		times = nil
+	}
+
+	profiles := make([][]profileSample, 0, len(lines))
+	distance, moving, index := 0.0, 0.0, 0
+	for _, line := range lines {
+		if len(line) < utils.MinSegmentPoints {
+			index += len(line)
+			continue
+		}
+
+		points := make([]profileSample, len(line))
+		elevations := make([]float64, len(line))
+		for i, coord := range line {
+			if i > 0 {
+				prev := line[i-1]
+				distance += utils.HaversineDistance(prev[1], prev[0], coord[1], coord[0])
+				if times != nil && times[index-1] != nil && times[index] != nil {
+					if dt := times[index].Sub(*times[index-1]).Seconds(); dt > 0 {
+						moving += dt
+					}
+				}
+			}
+			if len(coord) > 2 {
+				elevations[i] = coord[2]
+			}
+			points[i] = profileSample{Distance: distance, Moving: moving, HasTime: times != nil}
+			index++
+		}
+		for i, ele := range utils.SmoothElevations(elevations, utils.ElevationSmoothingWindow) {
+			points[i].Ele = ele
+		}
+
+		// Resample at fixed distances, always keeping the last point of the line
+		start, end := points[0].Distance, points[len(points)-1].Distance
+		samples := []profileSample{points[0]}
+		j := 0
+		for d := start + ClimbSampleDistance; d < end; d += ClimbSampleDistance {
+			for j < len(points)-2 && points[j+1].Distance < d {
+				j++
+			}
+			samples = append(samples, interpolateSample(points[j], points[j+1], d))
+		}
+		if end > start {
+			samples = append(samples, points[len(points)-1])
+		}
+		profiles = append(profiles, samples)
+	}
+	return profiles
+}
+
+// interpolateSample returns the sample at distance d between a and b
+func interpolateSample(a, b profileSample, d float64) profileSample {
+	ratio := 0.0
+	if b.Distance > a.Distance {
+		ratio = (d - a.Distance) / (b.Distance - a.Distance)
+	}
+	return profileSample{
+		Distance: d,
+		Ele:      a.Ele + (b.Ele-a.Ele)*ratio,
+		Moving:   a.Moving + (b.Moving-a.Moving)*ratio,
+		HasTime:  a.HasTime,
+	}
+}
+
+// detectClimbs finds ascents from a low point to the following high point that are not interrupted by a
+// descent larger than the drop tolerance, and keeps those that are long, high and steep enough
+func detectClimbs(profile []profileSample) []Climb {
+	var climbs []Climb
+	low, peak := 0, 0
+	for i := 1; i < len(profile); i++ {
+		ele := profile[i].Ele
+		if ele >= profile[peak].Ele {
+			peak = i
+			continue
+		}
+
+		gain := profile[peak].Ele - profile[low].Ele
+		if profile[peak].Ele-ele > math.Max(ClimbDropTolerance, gain*ClimbDropRatio) {
+			if climb, ok := newClimb(profile, low, peak); ok {
+				climbs = append(climbs, climb)
+			}
+			low, peak = i, i
+		} else if ele < profile[low].Ele {
+			low, peak = i, i
+		}
+	}
+	if climb, ok := newClimb(profile, low, peak); ok {
+		climbs = append(climbs, climb)
+	}
+	return climbs
+}
+
+// newClimb describes the ascent between two samples if it is significant. Flat parts at either end
+// (within the elevation hysteresis of the low and high point) are not counted as part of the climb.
+func newClimb(profile []profileSample, start, end int) (Climb, bool) {
+	low, high := profile[start].Ele, profile[end].Ele
+	for start+1 < end && profile[start+1].Ele-low <= utils.ElevationHysteresisMeters {
+		start++
+	}
+	for end-1 > start && high-profile[end-1].Ele <= utils.ElevationHysteresisMeters {
+		end--
+	}
+
+	length := profile[end].Distance - profile[start].Distance
+	gain := profile[end].Ele - profile[start].Ele
+	if length < MinClimbLength || gain < MinClimbGain {
+		return Climb{}, false
+	}
+	grade := gain / length * 100
+	if grade < MinClimbGrade {
+		return Climb{}, false
+	}
+
+	maxGrade, ok := maxAverageGrade(profile[start:end+1], MaxGradeDistance)
+	if !ok {
+		maxGrade = grade
+	}
+
+	climb := Climb{
+		StartDistanceKm: utils.RoundTo(profile[start].Distance/1000, 3),
+		EndDistanceKm:   utils.RoundTo(profile[end].Distance/1000, 3),
+		LengthKm:        utils.RoundTo(length/1000, 3),
+		ElevationGain:   utils.RoundTo(gain, 1),
+		StartElevation:  utils.RoundTo(profile[start].Ele, 1),
+		EndElevation:    utils.RoundTo(profile[end].Ele, 1),
+		AverageGrade:    utils.RoundTo(grade, 1),
+		MaxGrade:        utils.RoundTo(math.Max(maxGrade, grade), 1),
+		Score:           math.Round(length * grade),
+	}
+	for _, category := range climbCategories {
+		if climb.Score >= category.MinScore {
+			climb.Category = category.Name
+			break
+		}
+	}
+	return climb, true
+}
+
+// maxAverageGrade returns the steepest average grade over a window of the given distance, false if the profile is shorter
+func maxAverageGrade(profile []profileSample, window float64) (float64, bool) {
+	best, found := 0.0, false
+	j := 0
+	for i := range profile {
+		for j < len(profile) && profile[j].Distance-profile[i].Distance < window-1e-6 {
+			j++
+		}
+		if j == len(profile) {
+			break
+		}
+		grade := (profile[j].Ele - profile[i].Ele) / (profile[j].Distance - profile[i].Distance) * 100
+		if !found || grade > best {
+			best, found = grade, true
+		}
+	}
+	return utils.RoundTo(best, 1), found
+}
+
+// gradientHistogram sums the distance (and time, when available) spent in each grade bucket
+func gradientHistogram(profiles [][]profileSample) []GradeBucket {
+	buckets := make([]GradeBucket, len(gradeBucketBounds)+1)
+	for i := range buckets {
+		if i > 0 {
+			lower := gradeBucketBounds[i-1]
+			buckets[i].MinGrade = &lower
+		}
+		if i < len(gradeBucketBounds) {
+			upper := gradeBucketBounds[i]
+			buckets[i].MaxGrade = &upper
+		}
+	}
+
+	distances := make([]float64, len(buckets))
+	durations := make([]float64, len(buckets))
+	total, hasTime := 0.0, false
+	for _, profile := range profiles {
+		for i := 1; i < len(profile); i++ {
+			a, b := profile[i-1], profile[i]
+			length := b.Distance - a.Distance
+			if length <= 0 {
+				continue
+			}
+
+			grade := (b.Ele - a.Ele) / length * 100
+			bucket := len(gradeBucketBounds)
+			for k, bound := range gradeBucketBounds {
+				if grade < bound {
+					bucket = k
+					break
+				}
+			}
+			distances[bucket] += length
+			total += length
+			if a.HasTime {
+				hasTime = true
+				durations[bucket] += b.Moving - a.Moving
+			}
+		}
+	}
+
+	for i := range buckets {
+		buckets[i].DistanceKm = utils.RoundTo(distances[i]/1000, 3)
+		if total > 0 {
+			buckets[i].Percent = utils.RoundTo(distances[i]/total*100, 1)
+		}
+		if hasTime {
+			seconds := int(math.Round(durations[i]))
+			buckets[i].TimeSeconds = &seconds
+		}
+	}
+	return buckets
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -29,25 +30,46 @@ type GeoFeatures struct {
 	ConvexHull     *string  `json:"convex_hull"`
 	SimplifiedPath *string  `json:"simplified_path"`
 	RouteLength    *float64 `json:"route_length_km"`
+	RouteLength3D  *float64 `json:"route_length_3d_km"`
 	BoundingBox    *string  `json:"bounding_box"`
 }
 
 // ExtendedGeoFeatures includes both geographical and timing features
 type ExtendedGeoFeatures struct {
 	*GeoFeatures
-	StartTime        *string  `json:"start_time"`
-	EndTime          *string  `json:"end_time"`
-	Duration         *int     `json:"duration_minutes"`
-	AverageSpeed     *float64 `json:"average_speed_kmh"`
-	MaxElevationGain *float64 `json:"max_elevation_gain"`
+	StartTime          *string               `json:"start_time"`
+	EndTime            *string               `json:"end_time"`
+	Duration           *int                  `json:"duration_minutes"`
+	AverageSpeed       *float64              `json:"average_speed_kmh"`
+	MaxElevationGain   *float64              `json:"max_elevation_gain"`
+	TotalAscent        *float64              `json:"total_ascent"`
+	TotalDescent       *float64              `json:"total_descent"`
+	MinElevation       *float64              `json:"min_elevation"`
+	MaxElevation       *float64              `json:"max_elevation"`
+	MovingTime         *int                  `json:"moving_time_seconds"`
+	StoppedTime        *int                  `json:"stopped_time_seconds"`
+	ElapsedTime        *int                  `json:"elapsed_time_seconds"`
+	MovingAverageSpeed *float64              `json:"moving_average_speed_kmh"`
+	MaxSpeed           *float64              `json:"max_speed_kmh"`
+	AvgHeartRate       *float64              `json:"avg_heart_rate"`
+	MaxHeartRate       *float64              `json:"max_heart_rate"`
+	AvgCadence         *float64              `json:"avg_cadence"`
+	AvgPower           *float64              `json:"avg_power"`
+	NormalizedPower    *float64              `json:"normalized_power"`
+	AvgTemperature     *float64              `json:"avg_temperature"`
+	MaxSensorSpeed     *float64              `json:"max_sensor_speed_kmh"`
+	Segments           []utils.SegmentStats  `json:"segments"`
+	Climbs             *ClimbAnalysis        `json:"climbs"`
+	ProcessingReport   *utils.CleaningReport `json:"processing_report"`
 }
 
-// ProcessGeoJSONWithPostGIS processes GeoJSON data using PostGIS functions
-func (gs *GeoService) ProcessGeoJSONWithPostGIS(ctx context.Context, routeID uuid.UUID, geoJSONStr string) (*GeoFeatures, error) {
+// ProcessGeoJSONWithPostGIS processes GeoJSON data using PostGIS functions.
+// geometryJSON is the MultiLineString of all segments.
+func (gs *GeoService) ProcessGeoJSONWithPostGIS(ctx context.Context, routeID uuid.UUID, geometryJSON string) (*GeoFeatures, error) {
 	log.Printf("INFO: Processing GeoJSON with PostGIS for route: %s", routeID.String())
 
 	// Step 1: Store original geometry permanently in compact PostGIS format
-	err := gs.storeOriginalGeometry(ctx, routeID, geoJSONStr)
+	err := gs.storeOriginalGeometry(ctx, routeID, geometryJSON)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store original geometry: %w", err)
 	}
@@ -66,6 +88,11 @@ func (gs *GeoService) ProcessGeoJSONWithPostGIS(ctx context.Context, routeID uui
 		return nil, fmt.Errorf("failed to update route with geo features: %w", err)
 	}
 
+	// Precompute the levels of detail served to zoomed map views
+	if err := gs.storeSimplifiedPaths(ctx, routeID); err != nil {
+		return nil, err
+	}
+
 	// Step 4: Clean up temporary GeoJSON data
 	// err = gs.cleanupTemporaryGeoJSON(ctx, routeID)
 	if err != nil {
@@ -77,21 +104,17 @@ func (gs *GeoService) ProcessGeoJSONWithPostGIS(ctx context.Context, routeID uui
 	return features, nil
 }
 
-// storeOriginalGeometry stores the main route geometry in compact PostGIS format
-func (gs *GeoService) storeOriginalGeometry(ctx context.Context, routeID uuid.UUID, geoJSONStr string) error {
-	// Extract the main LineString geometry from GeoJSON and store in PostGIS format
+// storeOriginalGeometry stores the full route geometry (one part per segment) in compact PostGIS format
+func (gs *GeoService) storeOriginalGeometry(ctx context.Context, routeID uuid.UUID, geometryJSON string) error {
+	// Store the MultiLineString in PostGIS format, and its first point as geography for nearby searches
 	query := `
 		UPDATE routes SET 
-			original_geometry = (
-				SELECT ST_GeomFromGeoJSON(feature->>'geometry')
-				FROM jsonb_array_elements($1::jsonb->'features') as feature
-				WHERE feature->'geometry'->>'type' = 'LineString'
-				LIMIT 1
-			)
+			original_geometry = ST_Force3D(ST_GeomFromGeoJSON($1)),
+			start_point = ST_Force2D(ST_PointN(ST_GeometryN(ST_GeomFromGeoJSON($1), 1), 1))::geography
 		WHERE id = $2
 	`
 	
-	_, err := gs.db.Exec(ctx, query, geoJSONStr, routeID)
+	_, err := gs.db.Exec(ctx, query, geometryJSON, routeID)
 	if err != nil {
 		return fmt.Errorf("failed to store original geometry: %w", err)
 	}
@@ -99,6 +122,74 @@ func (gs *GeoService) storeOriginalGeometry(ctx context.Context, routeID uuid.UU
 	return nil
 }
 
+// storePointData stores the timestamp and heart rate of every point of the original geometry, used for profiles and splits
+func (gs *GeoService) storePointData(ctx context.Context, routeID uuid.UUID, pointTimes []*time.Time, heartRates []*float64) error {
+	if len(pointTimes) == 0 && len(heartRates) == 0 {
+		return nil
+	}
+
+	_, err := gs.db.Exec(ctx, "UPDATE routes SET point_times = $1, point_heart_rates = $2 WHERE id = $3", pointTimes, heartRates, routeID)
+	if err != nil {
+		return fmt.Errorf("failed to update point data: %w", err)
+	}
+	return nil
+}
+
+// StoreRouteWaypoints inserts the standalone waypoints of an uploaded file as points of interest of the route, keeping their order
+func (gs *GeoService) StoreRouteWaypoints(ctx context.Context, routeID uuid.UUID, waypoints []utils.Waypoint) error {
+	if len(waypoints) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(waypoints))
+	symbols := make([]*string, len(waypoints))
+	descriptions := make([]*string, len(waypoints))
+	lons := make([]float64, len(waypoints))
+	lats := make([]float64, len(waypoints))
+	elevations := make([]*float64, len(waypoints))
+	times := make([]*time.Time, len(waypoints))
+	for i := range waypoints {
+		wp := &waypoints[i]
+		if wp.Name != nil {
+			names[i] = truncateRunes(*wp.Name, 255)
+		}
+		if wp.Symbol != nil {
+			symbol := truncateRunes(*wp.Symbol, 100)
+			symbols[i] = &symbol
+		}
+		descriptions[i] = wp.Description
+		lons[i], lats[i] = wp.Lon, wp.Lat
+		elevations[i] = wp.Ele
+		times[i] = utils.WaypointTime(wp)
+	}
+
+	// Insert all waypoints in one statement, the array position becomes the display order
+	query := `
+		INSERT INTO route_waypoints (route_id, position, name, symbol, description, location, elevation, recorded_at)
+		SELECT $1, (w.ordinality - 1)::integer, w.name, w.symbol, w.description,
+		       ST_SetSRID(ST_MakePoint(w.lon, w.lat), 4326), w.elevation, w.recorded_at
+		FROM unnest($2::text[], $3::text[], $4::text[], $5::float8[], $6::float8[], $7::float8[], $8::timestamptz[])
+		     WITH ORDINALITY AS w(name, symbol, description, lon, lat, elevation, recorded_at, ordinality)
+	`
+
+	_, err := gs.db.Exec(ctx, query, routeID, names, symbols, descriptions, lons, lats, elevations, times)
+	if err != nil {
+		return fmt.Errorf("failed to insert route waypoints: %w", err)
+	}
+
+	log.Printf("INFO: Stored %d waypoints for route: %s", len(waypoints), routeID.String())
+	return nil
+}
+
+// truncateRunes shortens s to at most max characters
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
 // calculateGeoFeatures uses PostGIS to calculate geographical features from GeoJSON
 func (gs *GeoService) calculateGeoFeatures(ctx context.Context, routeID uuid.UUID) (*GeoFeatures, error) {
 	// Complex PostGIS query to calculate all geo features from stored original geometry
@@ -121,6 +212,12 @@ func (gs *GeoService) calculateGeoFeatures(ctx context.Context, routeID uuid.UUI
 			FROM geom_data 
 			WHERE geom IS NOT NULL 
 			LIMIT 1
+		),
+		slope_legs AS (
+			-- Consecutive points of each segment, the gap between two segments is not a leg
+			SELECT dp.geom as point,
+			       LAG(dp.geom) OVER (PARTITION BY dp.path[1] ORDER BY dp.path[2]) as prev
+			FROM main_geom, ST_DumpPoints(ST_Multi(main_geom.geom)) dp
 		)
 		SELECT 
 			-- Center point (centroid of the line) - force 3D
@@ -130,12 +227,17 @@ func (gs *GeoService) calculateGeoFeatures(ctx context.Context, routeID uuid.UUI
 			ST_AsText(ST_Force3D(ST_ConvexHull(geom))) as convex_hull,
 			
 			-- Simplified path (reduce points by ~95% for web display) - force 3D
-			-- Using tolerance of 0.001 degrees (~111 meters at equator)
-			ST_AsText(ST_Force3D(ST_Simplify(geom, 0.001))) as simplified_path,
+			-- Using tolerance of 0.001 degrees (~111 meters at equator), keeping short segments
+			ST_AsText(ST_Multi(ST_Force3D(ST_Simplify(geom, 0.001, true)))) as simplified_path,
 			
-			-- Route length in kilometers (works with both 2D and 3D)
-			-- Transform to Web Mercator for accurate distance calculation
-			ST_Length(ST_Transform(ST_Force2D(geom), 3857)) / 1000.0 as route_length_km,
+			-- Route length in kilometers, summed over all segments
+			-- Geodesic length on the spheroid; a Web Mercator length is inflated by 1/cos(latitude)
+			ST_Length(ST_Force2D(geom)::geography) / 1000.0 as route_length_km,
+			
+			-- 3D slope distance in kilometers: geodesic leg length combined with the elevation change
+			(SELECT COALESCE(SUM(sqrt(power(ST_Distance(prev::geography, point::geography), 2)
+			                          + power(ST_Z(point) - ST_Z(prev), 2))), 0) / 1000.0
+			 FROM slope_legs WHERE prev IS NOT NULL) as route_length_3d_km,
 			
 			-- Bounding box (envelope) - force 3D
 			ST_AsText(ST_Force3D(ST_Envelope(geom))) as bounding_box
@@ -149,6 +251,7 @@ func (gs *GeoService) calculateGeoFeatures(ctx context.Context, routeID uuid.UUI
 		&features.ConvexHull,
 		&features.SimplifiedPath,
 		&features.RouteLength,
+		&features.RouteLength3D,
 		&features.BoundingBox,
 	)
 
@@ -168,8 +271,9 @@ func (gs *GeoService) updateRouteWithGeoFeatures(ctx context.Context, routeID uu
 			simplified_path = ST_GeomFromText($3, 4326),
 			route_length_km = $4,
 			bounding_box = ST_GeomFromText($5, 4326),
+			route_length_3d_km = $6,
 			updated_at = NOW()
-		WHERE id = $6
+		WHERE id = $7
 	`
 
 	_, err := gs.db.Exec(ctx, query,
@@ -178,6 +282,7 @@ func (gs *GeoService) updateRouteWithGeoFeatures(ctx context.Context, routeID uu
 		*features.SimplifiedPath,
 		*features.RouteLength,
 		*features.BoundingBox,
+		features.RouteLength3D,
 		routeID,
 	)
 
@@ -189,64 +294,56 @@ func (gs *GeoService) updateRouteWithGeoFeatures(ctx context.Context, routeID uu
 }
 
 
-// GetRouteGeoFeatures retrieves geographical features for a route
-func (gs *GeoService) GetRouteGeoFeatures(ctx context.Context, routeID uuid.UUID) (*GeoFeatures, error) {
-	query := `
-		SELECT 
-			ST_AsText(center_point) as center_point,
-			ST_AsText(convex_hull) as convex_hull,
-			ST_AsText(simplified_path) as simplified_path,
-			route_length_km,
-			ST_AsText(bounding_box) as bounding_box
-		FROM routes 
-		WHERE id = $1
-	`
+// ProcessGPXAnalysis stores the geometry of an already analyzed GPX and combines geographical and timing features
+func (gs *GeoService) ProcessGPXAnalysis(ctx context.Context, routeID uuid.UUID, analysis *utils.GPXAnalysis) (*ExtendedGeoFeatures, error) {
+	gpxStats := analysis.Stats
 
-	var features GeoFeatures
-	err := gs.db.QueryRow(ctx, query, routeID).Scan(
-		&features.CenterPoint,
-		&features.ConvexHull,
-		&features.SimplifiedPath,
-		&features.RouteLength,
-		&features.BoundingBox,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to get route geo features: %w", err)
+	// Step 2: Serialize the route geometry for PostGIS
+	if analysis.Geometry == nil {
+		return nil, fmt.Errorf("no track or route with at least %d points found", utils.MinSegmentPoints)
 	}
-
-	return &features, nil
-}
-
-// ProcessGPXWithExtendedFeatures processes GPX content and calculates both geographical and timing features
-func (gs *GeoService) ProcessGPXWithExtendedFeatures(ctx context.Context, routeID uuid.UUID, gpxContent []byte) (*ExtendedGeoFeatures, error) {
-	log.Printf("INFO: Processing GPX with extended features for route: %s", routeID.String())
-
-	// Step 1: Analyze GPX for timing and elevation data
-	gpxStats, err := utils.AnalyzeGPXTiming(gpxContent)
+	geometryBytes, err := json.Marshal(analysis.Geometry)
 	if err != nil {
-		log.Printf("WARN: Failed to analyze GPX timing for route %s: %v", routeID.String(), err)
-		// Continue with geographical processing even if timing analysis fails
-		gpxStats = &utils.GPXStats{}
-	}
-
-	// Step 2: Convert GPX to GeoJSON and store original geometry
-	geoJSONStr, err := utils.ProcessGPXToGeoJSON(gpxContent)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert GPX to GeoJSON: %w", err)
+		return nil, fmt.Errorf("failed to marshal route geometry: %w", err)
 	}
 
 	// Step 3: Store original geometry and calculate geographical features
-	geoFeatures, err := gs.ProcessGeoJSONWithPostGIS(ctx, routeID, geoJSONStr)
+	geoFeatures, err := gs.ProcessGeoJSONWithPostGIS(ctx, routeID, string(geometryBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to process geographical features: %w", err)
 	}
+	if err := gs.storePointData(ctx, routeID, analysis.PointTimes, analysis.HeartRates); err != nil {
+		return nil, fmt.Errorf("failed to store point data: %w", err)
+	}
 
 	// Step 4: Combine features and calculate average speed
 	extended := &ExtendedGeoFeatures{
-		GeoFeatures:      geoFeatures,
-		Duration:         gpxStats.Duration,
-		MaxElevationGain: gpxStats.MaxElevationGain,
+		GeoFeatures:        geoFeatures,
+		Duration:           gpxStats.Duration,
+		MaxElevationGain:   gpxStats.MaxElevationGain,
+		TotalAscent:        gpxStats.TotalAscent,
+		TotalDescent:       gpxStats.TotalDescent,
+		MinElevation:       gpxStats.MinElevation,
+		MaxElevation:       gpxStats.MaxElevation,
+		MovingTime:         gpxStats.MovingTime,
+		StoppedTime:        gpxStats.StoppedTime,
+		ElapsedTime:        gpxStats.ElapsedTime,
+		MovingAverageSpeed: gpxStats.MovingAverageSpeed,
+		MaxSpeed:           gpxStats.MaxSpeed,
+		AvgHeartRate:       gpxStats.AvgHeartRate,
+		MaxHeartRate:       gpxStats.MaxHeartRate,
+		AvgCadence:         gpxStats.AvgCadence,
+		AvgPower:           gpxStats.AvgPower,
+		NormalizedPower:    gpxStats.NormalizedPower,
+		AvgTemperature:     gpxStats.AvgTemperature,
+		MaxSensorSpeed:     gpxStats.MaxSensorSpeed,
+		Segments:           analysis.Segments,
+		ProcessingReport:   analysis.Cleaning,
+	}
+
+	// Detect climbs and grade distribution from the same geometry that was stored
+	if lines, ok := analysis.Geometry.Coordinates.([][][]float64); ok {
+		extended.Climbs = AnalyzeClimbs(lines, analysis.PointTimes)
 	}
 
 	// Convert timestamps to string format for storage
@@ -284,8 +381,30 @@ func (gs *GeoService) UpdateRouteWithExtendedFeatures(ctx context.Context, route
 			estimated_duration = $8,
 			average_speed = $9,
 			max_elevation_gain = $10,
+			total_ascent = $11,
+			total_descent = $12,
+			min_elevation = $13,
+			max_elevation = $14,
+			moving_time_seconds = $15,
+			stopped_time_seconds = $16,
+			elapsed_time_seconds = $17,
+			moving_average_speed = $18,
+			max_speed = $19,
+			avg_heart_rate = $20,
+			max_heart_rate = $21,
+			avg_cadence = $22,
+			avg_power = $23,
+			normalized_power = $24,
+			segments = $25,
+			climbs = $26,
+			gradient_histogram = $27,
+			max_sustained_grade = $28,
+			processing_report = $29,
+			route_length_3d_km = $30,
+			avg_temperature = $31,
+			max_sensor_speed_kmh = $32,
 			updated_at = NOW()
-		WHERE id = $11
+		WHERE id = $33
 	`
 
 	// Convert string timestamps back to time.Time for database storage
@@ -301,6 +420,37 @@ func (gs *GeoService) UpdateRouteWithExtendedFeatures(ctx context.Context, route
 		}
 	}
 
+	// Store the per-segment breakdown as JSON, NULL when there is none
+	var segments []byte
+	if len(features.Segments) > 0 {
+		var err error
+		if segments, err = json.Marshal(features.Segments); err != nil {
+			return fmt.Errorf("failed to marshal route segments: %w", err)
+		}
+	}
+
+	// Store climbs and the gradient histogram as JSON, NULL when the route has no elevation data
+	var climbs, gradientHistogram []byte
+	var maxSustainedGrade *float64
+	if features.Climbs != nil {
+		var err error
+		if climbs, err = json.Marshal(features.Climbs.Climbs); err != nil {
+			return fmt.Errorf("failed to marshal route climbs: %w", err)
+		}
+		if gradientHistogram, err = json.Marshal(features.Climbs.GradientHistogram); err != nil {
+			return fmt.Errorf("failed to marshal gradient histogram: %w", err)
+		}
+		maxSustainedGrade = features.Climbs.MaxSustainedGrade
+	}
+
+	var processingReport []byte
+	if features.ProcessingReport != nil {
+		var err error
+		if processingReport, err = json.Marshal(features.ProcessingReport); err != nil {
+			return fmt.Errorf("failed to marshal processing report: %w", err)
+		}
+	}
+
 	_, err := gs.db.Exec(ctx, query,
 		*features.CenterPoint,
 		*features.ConvexHull,
@@ -312,6 +462,28 @@ func (gs *GeoService) UpdateRouteWithExtendedFeatures(ctx context.Context, route
 		features.Duration,
 		features.AverageSpeed,
 		features.MaxElevationGain,
+		features.TotalAscent,
+		features.TotalDescent,
+		features.MinElevation,
+		features.MaxElevation,
+		features.MovingTime,
+		features.StoppedTime,
+		features.ElapsedTime,
+		features.MovingAverageSpeed,
+		features.MaxSpeed,
+		features.AvgHeartRate,
+		features.MaxHeartRate,
+		features.AvgCadence,
+		features.AvgPower,
+		features.NormalizedPower,
+		segments,
+		climbs,
+		gradientHistogram,
+		maxSustainedGrade,
+		processingReport,
+		features.RouteLength3D,
+		features.AvgTemperature,
+		features.MaxSensorSpeed,
 		routeID,
 	)
 
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WebMercatorMetersPerPixel is the ground resolution of a 256 px web map tile at zoom 0 on the equator
+const WebMercatorMetersPerPixel = 156543.03392
+
+// MaxMapZoom is the highest zoom level accepted from clients
+const MaxMapZoom = 24
+
+// SimplificationLevel is a precomputed level of detail of the route path
+type SimplificationLevel struct {
+	Column          string  `json:"-"`
+	ToleranceMeters float64 `json:"tolerance_m"`
+}
+
+// SimplificationLevels are stored for every route, finest first. Each path is simplified with
+// ST_SimplifyPreserveTopology in Web Mercator, with the tolerance scaled to meters at the route's latitude.
+var SimplificationLevels = []SimplificationLevel{
+	{Column: "simplified_path_2m", ToleranceMeters: 2},
+	{Column: "simplified_path_10m", ToleranceMeters: 10},
+	{Column: "simplified_path_50m", ToleranceMeters: 50},
+	{Column: "simplified_path_250m", ToleranceMeters: 250},
+	{Column: "simplified_path_1000m", ToleranceMeters: 1000},
+}
+
+// MetersPerPixel returns the ground resolution of a web map at the given zoom level and latitude
+func MetersPerPixel(zoom, latitude float64) float64 {
+	return WebMercatorMetersPerPixel * math.Cos(latitude*math.Pi/180) / math.Pow(2, zoom)
+}
+
+// SelectSimplificationLevel returns the coarsest level whose tolerance does not exceed the requested one,
+// or the finest level when the request is finer than all of them
+func SelectSimplificationLevel(toleranceMeters float64) SimplificationLevel {
+	selected := SimplificationLevels[0]
+	for _, level := range SimplificationLevels {
+		if level.ToleranceMeters <= toleranceMeters {
+			selected = level
+		}
+	}
+	return selected
+}
+
+// storeSimplifiedPaths computes every level of detail from the stored original geometry
+func (gs *GeoService) storeSimplifiedPaths(ctx context.Context, routeID uuid.UUID) error {
+	// One Web Mercator unit is cos(latitude) meters on the ground, so a tolerance in meters is scaled by 1/cos(latitude)
+	setParts := make([]string, 0, len(SimplificationLevels))
+	for _, level := range SimplificationLevels {
+		setParts = append(setParts, fmt.Sprintf(
+			"%s = ST_Multi(ST_Force3D(ST_Transform(ST_SimplifyPreserveTopology(ST_Transform(original_geometry, 3857), %g * s.scale), 4326)))",
+			level.Column, level.ToleranceMeters))
+	}
+
+	query := `
+		UPDATE routes SET ` + strings.Join(setParts, ",\n\t\t\t") + `
+		FROM (
+			SELECT id, 1.0 / GREATEST(cos(radians(ST_Y(ST_Centroid(original_geometry)))), 0.01) as scale
+			FROM routes
+			WHERE id = $1 AND original_geometry IS NOT NULL
+		) s
+		WHERE routes.id = s.id
+	`
+
+	if _, err := gs.db.Exec(ctx, query, routeID); err != nil {
+		return fmt.Errorf("failed to store simplified paths: %w", err)
+	}
+
+	log.Printf("INFO: Stored %d simplified path levels for route: %s", len(SimplificationLevels), routeID.String())
+	return nil
+}
@@ -1,9 +1,8 @@
 package storage
 
 import (
+	"fmt"
 	"io"
-	"log"
-	"strings"
 	"time"
 )
 
@@ -17,6 +16,12 @@ type FileStorage interface {
 
 	// GetPresignedURLWithFilename generates a temporary URL for file access with a specified filename
 	GetPresignedURLWithFilename(key string, duration time.Duration, filename string) (string, error)
+
+	// GetPresignedUploadURL generates a temporary URL the client can PUT a file to
+	GetPresignedUploadURL(key string, duration time.Duration) (string, error)
+
+	// OpenFile opens a stored file for reading along with its content type and size
+	OpenFile(key string) (io.ReadCloser, string, int64, error)
 	
 	// DeleteFile removes a file from storage
 	DeleteFile(key string) error
@@ -25,14 +30,18 @@ type FileStorage interface {
 	FileExists(key string) (bool, error)
 }
 
-// GenerateObjectKey creates a standardized object key for GPX files
-func GenerateObjectKey(userID, fileID, filename string) string {
-	log.Printf("INFO: Generating object key for user %s, file %s, filename %s", userID, fileID, filename)
-	// Extract file extension
-	ext := ""
-	if idx := strings.LastIndex(filename, "."); idx >= 0 {
-		ext = filename[idx:]
-	}
-	// Generate clean key with just ID and extension
-	return "gpx/" + userID + "/" + fileID + ext
+// GenerateBlobKey creates the content-addressed object key of a file from its hex SHA-256 and extension,
+// so identical files share one stored object
+func GenerateBlobKey(sha256Hex, ext string) string {
+	return "blobs/sha256/" + sha256Hex[:2] + "/" + sha256Hex + ext
+}
+
+// GenerateUploadKey creates the staging object key of a direct upload
+func GenerateUploadKey(userID, uploadID string) string {
+	return "uploads/" + userID + "/" + uploadID
+}
+
+// GenerateChunkKey creates the object key of a resumable upload chunk starting at offset
+func GenerateChunkKey(userID, uploadID string, offset int64) string {
+	return fmt.Sprintf("tus/%s/%s/%020d", userID, uploadID, offset)
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// LocalStorage implements FileStorage on the local filesystem, with downloads served by the backend
+type LocalStorage struct {
+	*URLSigner
+	rootDir string
+}
+
+// NewLocalStorage creates a storage rooted at rootDir, creating the directory if needed
+func NewLocalStorage(rootDir string, signer *URLSigner) (*LocalStorage, error) {
+	if rootDir == "" {
+		return nil, fmt.Errorf("missing local storage directory")
+	}
+	if err := os.MkdirAll(rootDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage directory: %w", err)
+	}
+	return &LocalStorage{
+		URLSigner: signer,
+		rootDir:   rootDir,
+	}, nil
+}
+
+// filePath maps an object key to its path under the root directory
+func (l *LocalStorage) filePath(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.rootDir, filepath.FromSlash(key)), nil
+}
+
+// UploadFile writes a file to the local storage, replacing it atomically
+func (l *LocalStorage) UploadFile(key string, file io.Reader, contentType string) error {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file for %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, file); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to store file %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// GetPresignedURL generates a signed URL served by the backend
+func (l *LocalStorage) GetPresignedURL(key string, duration time.Duration) (string, error) {
+	return l.SignURL(http.MethodGet, key, duration, "")
+}
+
+// GetPresignedURLWithFilename generates a signed URL served by the backend as an attachment named filename
+func (l *LocalStorage) GetPresignedURLWithFilename(key string, duration time.Duration, filename string) (string, error) {
+	return l.SignURL(http.MethodGet, key, duration, filename)
+}
+
+// GetPresignedUploadURL generates a signed URL the client can PUT a file to, served by the backend
+func (l *LocalStorage) GetPresignedUploadURL(key string, duration time.Duration) (string, error) {
+	return l.SignURL(http.MethodPut, key, duration, "")
+}
+
+// DeleteFile removes a file from the local storage
+func (l *LocalStorage) DeleteFile(key string) error {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file %s: %w", key, err)
+	}
+	return nil
+}
+
+// FileExists checks if a file exists in the local storage
+func (l *LocalStorage) FileExists(key string) (bool, error) {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check file existence: %w", err)
+	}
+	return info.Mode().IsRegular(), nil
+}
+
+// OpenFile opens a stored file for reading, with the content type guessed from its extension
+func (l *LocalStorage) OpenFile(key string) (io.ReadCloser, string, int64, error) {
+	filePath, err := l.filePath(key)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, "", 0, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to open file %s: %w", key, err)
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		return nil, "", 0, ErrFileNotFound
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return file, contentType, info.Size(), nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// memoryFile is a file held by MemoryStorage
+type memoryFile struct {
+	data        []byte
+	contentType string
+}
+
+// MemoryStorage implements FileStorage in memory, for development and tests. Files are lost on restart.
+type MemoryStorage struct {
+	*URLSigner
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+// NewMemoryStorage creates an empty in-memory storage
+func NewMemoryStorage(signer *URLSigner) *MemoryStorage {
+	return &MemoryStorage{
+		URLSigner: signer,
+		files:     make(map[string]memoryFile),
+	}
+}
+
+// UploadFile stores a copy of the file in memory
+func (m *MemoryStorage) UploadFile(key string, file io.Reader, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", key, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[key] = memoryFile{data: data, contentType: contentType}
+	return nil
+}
+
+// GetPresignedURL generates a signed URL served by the backend
+func (m *MemoryStorage) GetPresignedURL(key string, duration time.Duration) (string, error) {
+	return m.SignURL(http.MethodGet, key, duration, "")
+}
+
+// GetPresignedURLWithFilename generates a signed URL served by the backend as an attachment named filename
+func (m *MemoryStorage) GetPresignedURLWithFilename(key string, duration time.Duration, filename string) (string, error) {
+	return m.SignURL(http.MethodGet, key, duration, filename)
+}
+
+// GetPresignedUploadURL generates a signed URL the client can PUT a file to, served by the backend
+func (m *MemoryStorage) GetPresignedUploadURL(key string, duration time.Duration) (string, error) {
+	return m.SignURL(http.MethodPut, key, duration, "")
+}
+
+// DeleteFile removes a file from memory
+func (m *MemoryStorage) DeleteFile(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, key)
+	return nil
+}
+
+// FileExists checks if a file is held in memory
+func (m *MemoryStorage) FileExists(key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.files[key]
+	return ok, nil
+}
+
+// OpenFile returns a reader over a stored file
+func (m *MemoryStorage) OpenFile(key string) (io.ReadCloser, string, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	file, ok := m.files[key]
+	if !ok {
+		return nil, "", 0, ErrFileNotFound
+	}
+	return io.NopCloser(bytes.NewReader(file.data)), file.contentType, int64(len(file.data)), nil
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Options configures an S3-compatible storage such as AWS S3, MinIO, Ceph or Cloudflare R2
+type S3Options struct {
+	Endpoint             string // custom endpoint URL, empty for AWS S3
+	Region               string
+	Bucket               string
+	AccessKeyID          string // static credentials, empty to use the default AWS credential chain
+	SecretAccessKey      string
+	UsePathStyle         bool   // address buckets as <endpoint>/<bucket>, required by MinIO and most self-hosted servers
+	ServerSideEncryption string // AES256, aws:kms or empty for the bucket default
+	SSEKMSKeyID          string // KMS key used with aws:kms
+	StorageClass         string // e.g. STANDARD_IA, empty for the bucket default
+}
+
+// S3Storage implements FileStorage interface for S3-compatible object storage
+type S3Storage struct {
+	client  *s3.Client
+	options S3Options
+}
+
+// NewS3Storage creates a new S3 storage client
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("missing S3 bucket name")
+	}
+	if opts.Region == "" {
+		return nil, fmt.Errorf("missing S3 region")
+	}
+	if (opts.AccessKeyID == "") != (opts.SecretAccessKey == "") {
+		return nil, fmt.Errorf("S3 access key ID and secret access key must be set together")
+	}
+	if opts.ServerSideEncryption != "" && !slices.Contains(types.ServerSideEncryption("").Values(), types.ServerSideEncryption(opts.ServerSideEncryption)) {
+		return nil, fmt.Errorf("unsupported S3 server-side encryption %q", opts.ServerSideEncryption)
+	}
+	if opts.SSEKMSKeyID != "" && opts.ServerSideEncryption != string(types.ServerSideEncryptionAwsKms) {
+		return nil, fmt.Errorf("S3 SSE KMS key ID requires server-side encryption %s", types.ServerSideEncryptionAwsKms)
+	}
+	if opts.StorageClass != "" && !slices.Contains(types.StorageClass("").Values(), types.StorageClass(opts.StorageClass)) {
+		return nil, fmt.Errorf("unsupported S3 storage class %q", opts.StorageClass)
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{config.WithRegion(opts.Region)}
+	if opts.AccessKeyID != "" {
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(opts.AccessKeyID, opts.SecretAccessKey, "")))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load S3 config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+		o.UsePathStyle = opts.UsePathStyle
+	})
+
+	return &S3Storage{
+		client:  client,
+		options: opts,
+	}, nil
+}
+
+// UploadFile uploads a file to S3 storage
+func (r *S3Storage) UploadFile(key string, file io.Reader, contentType string) error {
+	ctx := context.Background()
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(r.options.Bucket),
+		Key:         aws.String(key),
+		Body:        file,
+		ContentType: aws.String(contentType),
+	}
+	if r.options.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(r.options.ServerSideEncryption)
+	}
+	if r.options.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(r.options.SSEKMSKeyID)
+	}
+	if r.options.StorageClass != "" {
+		input.StorageClass = types.StorageClass(r.options.StorageClass)
+	}
+
+	_, err := r.client.PutObject(ctx, input)
+
+	if err != nil {
+		return fmt.Errorf("failed to upload file to S3: %w", err)
+	}
+
+	return nil
+}
+
+// GetPresignedURL generates a presigned URL for file access
+func (r *S3Storage) GetPresignedURL(key string, duration time.Duration) (string, error) {
+	ctx := context.Background()
+
+	presignClient := s3.NewPresignClient(r.client)
+
+	// Set ResponseContentDisposition to specify the filename for download
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.options.Bucket),
+		Key:    aws.String(key),
+	}
+
+	req, err := presignClient.PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = duration
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	url := req.URL
+	if strings.Contains(url, "\\u0026") {
+		url = strings.ReplaceAll(url, "\\u0026", "&")
+	}
+
+	return url, nil
+}
+
+func (r *S3Storage) GetPresignedURLWithFilename(key string, duration time.Duration, filename string) (string, error) {
+	ctx := context.Background()
+
+	presignClient := s3.NewPresignClient(r.client)
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.options.Bucket),
+		Key:    aws.String(key),
+		ResponseContentDisposition: aws.String(fmt.Sprintf(`attachment; filename="%s"`, filename)),
+	}
+
+	req, err := presignClient.PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = duration
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL with filename: %w", err)
+	}
+
+	url := req.URL
+	if strings.Contains(url, "\\u0026") {
+		url = strings.ReplaceAll(url, "\\u0026", "&")
+	}
+
+	return url, nil
+}
+
+// GetPresignedUploadURL generates a presigned URL the client can PUT a file to
+func (r *S3Storage) GetPresignedUploadURL(key string, duration time.Duration) (string, error) {
+	ctx := context.Background()
+
+	presignClient := s3.NewPresignClient(r.client)
+
+	// Encryption and storage class are signed headers, the client must send them with the upload
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(r.options.Bucket),
+		Key:    aws.String(key),
+	}
+	if r.options.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(r.options.ServerSideEncryption)
+	}
+	if r.options.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(r.options.SSEKMSKeyID)
+	}
+	if r.options.StorageClass != "" {
+		input.StorageClass = types.StorageClass(r.options.StorageClass)
+	}
+
+	req, err := presignClient.PresignPutObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = duration
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+
+	return req.URL, nil
+}
+
+// OpenFile opens a file in S3 storage for reading
+func (r *S3Storage) OpenFile(key string) (io.ReadCloser, string, int64, error) {
+	ctx := context.Background()
+
+	output, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.options.Bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", 0, ErrFileNotFound
+		}
+		return nil, "", 0, fmt.Errorf("failed to open file from S3: %w", err)
+	}
+
+	return output.Body, aws.ToString(output.ContentType), aws.ToInt64(output.ContentLength), nil
+}
+
+// DeleteFile removes a file from S3 storage
+func (r *S3Storage) DeleteFile(key string) error {
+	ctx := context.Background()
+
+	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r.options.Bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %w", err)
+	}
+
+	return nil
+}
+
+// FileExists checks if a file exists in S3 storage
+func (r *S3Storage) FileExists(key string) (bool, error) {
+	ctx := context.Background()
+
+	_, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.options.Bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		// Check if it's a "not found" error by checking error message
+		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "404") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check file existence: %w", err)
+	}
+
+	return true, nil
+}
\ No newline at end of file
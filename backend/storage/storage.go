@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+// Storage backends selectable through Options.Backend
+const (
+	BackendR2     = "r2"
+	BackendS3     = "s3"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+)
+
+// ErrFileNotFound is returned when a key does not exist in storage
+var ErrFileNotFound = errors.New("file not found")
+
+// Options selects and configures the storage backend
+type Options struct {
+	Backend    string    // r2, s3, local or memory
+	S3         S3Options // settings of the s3 backend
+	LocalDir   string    // root directory of the local backend
+	SigningKey []byte    // HMAC key of the download URLs served by the backend itself
+	PublicURL  string    // URL under which the backend serves signed downloads, e.g. http://localhost:8000/api/v1/files
+}
+
+// SignedFileStorage is implemented by backends whose download and upload URLs are served by this application
+// rather than by the storage provider
+type SignedFileStorage interface {
+	FileStorage
+
+	// VerifyURL checks the signature and expiry of a download (GET) or upload (PUT) URL generated for key
+	VerifyURL(method, key, expires, filename, signature string) error
+}
+
+// NewFileStorage creates the storage backend selected by the options
+func NewFileStorage(opts Options) (FileStorage, error) {
+	switch opts.Backend {
+	case BackendR2, "":
+		return NewR2Storage()
+	case BackendS3:
+		return NewS3Storage(opts.S3)
+	case BackendLocal:
+		return NewLocalStorage(opts.LocalDir, NewURLSigner(opts.SigningKey, opts.PublicURL))
+	case BackendMemory:
+		log.Printf("WARN: Using in-memory storage, uploaded files are lost on restart")
+		return NewMemoryStorage(NewURLSigner(opts.SigningKey, opts.PublicURL)), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q: must be %s, %s, %s or %s", opts.Backend, BackendR2, BackendS3, BackendLocal, BackendMemory)
+	}
+}
+
+// validateKey rejects keys that could escape the storage root
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
@@ -33,7 +33,7 @@ SELECT
     ST_AsText(ST_Force3D(ST_Centroid(geom))) as center_point,
     ST_AsText(ST_Force3D(ST_ConvexHull(geom))) as convex_hull,
     ST_AsText(ST_Force3D(ST_Simplify(geom, 0.001))) as simplified_path,
-    ST_Length(ST_Transform(ST_Force2D(geom), 3857)) / 1000.0 as route_length_km,
+    ST_Length(ST_Force2D(geom)::geography) / 1000.0 as route_length_km,
     ST_AsText(ST_Force3D(ST_Envelope(geom))) as bounding_box
 FROM main_geom;
 
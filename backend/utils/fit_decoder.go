@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// FIT global message numbers used when converting activities
+const (
+	fitMesgNumEvent  = 21
+	fitMesgNumRecord = 20
+)
+
+// FIT record message field numbers
+const (
+	fitFieldTimestamp        = 253
+	fitFieldPositionLat      = 0
+	fitFieldPositionLong     = 1
+	fitFieldAltitude         = 2
+	fitFieldHeartRate        = 3
+	fitFieldCadence          = 4
+	fitFieldSpeed            = 6
+	fitFieldPower            = 7
+	fitFieldTemperature      = 13
+	fitFieldEnhancedSpeed    = 73
+	fitFieldEnhancedAltitude = 78
+)
+
+// FIT event message values that mark a recording pause
+const (
+	fitFieldEvent       = 0
+	fitFieldEventType   = 1
+	fitEventTimer       = 0
+	fitEventTypeStop    = 1
+	fitEventTypeStopAll = 4
+)
+
+// fitEpoch is the FIT timestamp origin (1989-12-31T00:00:00Z)
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// semicirclesToDegrees is the conversion factor for FIT position fields
+const semicirclesToDegrees = 180.0 / (1 << 31)
+
+// ErrNotFIT is returned when the data does not start with a FIT file header
+var ErrNotFIT = errors.New("document is not a FIT file")
+
+// fitFieldDef describes one field of a FIT definition message
+type fitFieldDef struct {
+	Num  byte
+	Size byte
+}
+
+// fitDefinition describes the layout of data messages for a local message type
+type fitDefinition struct {
+	GlobalNum  uint16
+	BigEndian  bool
+	Fields     []fitFieldDef
+	DevDataLen int
+}
+
+// fitDecoder reads FIT messages sequentially from a stream
+type fitDecoder struct {
+	r             *bufio.Reader
+	remaining     int64
+	crc           uint16
+	definitions   [16]*fitDefinition
+	lastTimestamp uint32
+	msg           fitMessage
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitCRC updates a FIT CRC-16 with the given bytes
+func fitCRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// read reads exactly n bytes of the data section and updates the running CRC
+func (d *fitDecoder) read(n int) ([]byte, error) {
+	if int64(n) > d.remaining {
+		return nil, fmt.Errorf("FIT message exceeds data size")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read FIT data: %w", err)
+	}
+	d.remaining -= int64(n)
+	d.crc = fitCRC(d.crc, buf)
+	return buf, nil
+}
+
+// readDefinition parses a definition message for the given local message type
+func (d *fitDecoder) readDefinition(header byte) error {
+	fixed, err := d.read(5)
+	if err != nil {
+		return err
+	}
+
+	def := &fitDefinition{BigEndian: fixed[1] == 1}
+	if def.BigEndian {
+		def.GlobalNum = binary.BigEndian.Uint16(fixed[2:4])
+	} else {
+		def.GlobalNum = binary.LittleEndian.Uint16(fixed[2:4])
+	}
+
+	fields, err := d.read(int(fixed[4]) * 3)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(fields); i += 3 {
+		def.Fields = append(def.Fields, fitFieldDef{Num: fields[i], Size: fields[i+1]})
+	}
+
+	// Developer fields are skipped, only their total size is needed
+	if header&0x20 != 0 {
+		count, err := d.read(1)
+		if err != nil {
+			return err
+		}
+		devFields, err := d.read(int(count[0]) * 3)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(devFields); i += 3 {
+			def.DevDataLen += int(devFields[i+1])
+		}
+	}
+
+	d.definitions[header&0x0F] = def
+	return nil
+}
+
+// fitMessage is a decoded data message with raw unsigned field values indexed by field number. A size of 0
+// marks a field that is not present.
+type fitMessage struct {
+	GlobalNum uint16
+	Fields    [256]uint64
+	Sizes     [256]byte
+}
+
+// readData parses a data message using a previously seen definition. The decoder reuses the returned message,
+// it is only valid until the next call.
+func (d *fitDecoder) readData(local byte) (*fitMessage, error) {
+	def := d.definitions[local]
+	if def == nil {
+		return nil, fmt.Errorf("FIT data message for undefined local type %d", local)
+	}
+
+	msg := &d.msg
+	msg.GlobalNum = def.GlobalNum
+	msg.Sizes = [256]byte{}
+	for _, field := range def.Fields {
+		raw, err := d.read(int(field.Size))
+		if err != nil {
+			return nil, err
+		}
+
+		var value uint64
+		switch field.Size {
+		case 1:
+			value = uint64(raw[0])
+		case 2:
+			if def.BigEndian {
+				value = uint64(binary.BigEndian.Uint16(raw))
+			} else {
+				value = uint64(binary.LittleEndian.Uint16(raw))
+			}
+		case 4:
+			if def.BigEndian {
+				value = uint64(binary.BigEndian.Uint32(raw))
+			} else {
+				value = uint64(binary.LittleEndian.Uint32(raw))
+			}
+		default:
+			// Arrays and strings are not needed for track conversion
+			continue
+		}
+		msg.Fields[field.Num] = value
+		msg.Sizes[field.Num] = field.Size
+	}
+
+	if def.DevDataLen > 0 {
+		if _, err := d.read(def.DevDataLen); err != nil {
+			return nil, err
+		}
+	}
+
+	if ts := msg.Fields[fitFieldTimestamp]; msg.Sizes[fitFieldTimestamp] != 0 && ts != math.MaxUint32 {
+		d.lastTimestamp = uint32(ts)
+	}
+	return msg, nil
+}
+
+// next returns the next data message, or io.EOF at the end of the data section. The message is only valid
+// until the next call.
+func (d *fitDecoder) next() (*fitMessage, error) {
+	for d.remaining > 0 {
+		header, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Compressed timestamp header: the time offset is relative to the last full timestamp
+		if header[0]&0x80 != 0 {
+			local := (header[0] >> 5) & 0x03
+			offset := uint32(header[0] & 0x1F)
+			timestamp := (d.lastTimestamp &^ 0x1F) + offset
+			if offset < d.lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+
+			msg, err := d.readData(local)
+			if err != nil {
+				return nil, err
+			}
+			msg.Fields[fitFieldTimestamp] = uint64(timestamp)
+			msg.Sizes[fitFieldTimestamp] = 4
+			d.lastTimestamp = timestamp
+			return msg, nil
+		}
+
+		if header[0]&0x40 != 0 {
+			if err := d.readDefinition(header[0]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return d.readData(header[0] & 0x0F)
+	}
+	return nil, io.EOF
+}
+
+// fitValue returns a field value when it is present and not the FIT "invalid" marker
+func fitValue(msg *fitMessage, num byte) (uint64, bool) {
+	value := msg.Fields[num]
+	switch msg.Sizes[num] {
+	case 0:
+		return 0, false
+	case 1:
+		return value, value != 0xFF
+	case 2:
+		return value, value != 0xFFFF
+	case 4:
+		return value, value != 0xFFFFFFFF
+	}
+	return value, true
+}
+
+// fitSignedValue returns a signed field value, treating the FIT "invalid" marker as missing
+func fitSignedValue(msg *fitMessage, num byte) (int64, bool) {
+	value := msg.Fields[num]
+	switch msg.Sizes[num] {
+	case 0:
+		return 0, false
+	case 1:
+		return int64(int8(value)), value != 0x7F
+	case 2:
+		return int64(int16(value)), value != 0x7FFF
+	case 4:
+		return int64(int32(value)), value != 0x7FFFFFFF
+	}
+	return int64(value), true
+}
+
+// fitRecordToWaypoint converts a FIT record message into a GPX track point
+func fitRecordToWaypoint(msg *fitMessage) (Waypoint, bool) {
+	lat, okLat := fitSignedValue(msg, fitFieldPositionLat)
+	lon, okLon := fitSignedValue(msg, fitFieldPositionLong)
+	if !okLat || !okLon {
+		// Records without a position (e.g. indoor or before GPS fix) cannot be placed on a track
+		return Waypoint{}, false
+	}
+
+	wp := Waypoint{
+		Lat: float64(lat) * semicirclesToDegrees,
+		Lon: float64(lon) * semicirclesToDegrees,
+	}
+
+	if ts, ok := fitValue(msg, fitFieldTimestamp); ok {
+		t := fitEpoch.Add(time.Duration(ts) * time.Second).Format(time.RFC3339)
+		wp.Time = &t
+	}
+
+	// Altitude is stored with scale 5 and offset 500 m
+	if alt, ok := fitValue(msg, fitFieldEnhancedAltitude); ok {
+		ele := float64(alt)/5 - 500
+		wp.Ele = &ele
+	} else if alt, ok := fitValue(msg, fitFieldAltitude); ok {
+		ele := float64(alt)/5 - 500
+		wp.Ele = &ele
+	}
+
+	sensors := &SensorData{}
+	if hr, ok := fitValue(msg, fitFieldHeartRate); ok {
+		value := float64(hr)
+		sensors.HeartRate = &value
+	}
+	if cad, ok := fitValue(msg, fitFieldCadence); ok {
+		value := float64(cad)
+		sensors.Cadence = &value
+	}
+	if power, ok := fitValue(msg, fitFieldPower); ok {
+		value := float64(power)
+		sensors.Power = &value
+	}
+	if temp, ok := fitSignedValue(msg, fitFieldTemperature); ok {
+		value := float64(temp)
+		sensors.Temperature = &value
+	}
+	// Speed is stored in mm/s
+	if speed, ok := fitValue(msg, fitFieldEnhancedSpeed); ok {
+		value := float64(speed) / 1000
+		sensors.Speed = &value
+	} else if speed, ok := fitValue(msg, fitFieldSpeed); ok {
+		value := float64(speed) / 1000
+		sensors.Speed = &value
+	}
+	if !sensors.IsEmpty() {
+		wp.Sensors = sensors
+	}
+
+	return wp, true
+}
+
+// DecodeFIT converts the record messages of a FIT activity into the internal GPX representation.
+// Timer stop events start a new track segment so pauses are preserved.
+func DecodeFIT(r io.Reader) (*GPX, error) {
+	br := bufio.NewReader(r)
+
+	headerSize, err := br.Peek(1)
+	if err != nil || (headerSize[0] != 12 && headerSize[0] != 14) {
+		return nil, ErrNotFIT
+	}
+	header := make([]byte, headerSize[0])
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrNotFIT
+	}
+	if string(header[8:12]) != ".FIT" {
+		return nil, ErrNotFIT
+	}
+
+	decoder := &fitDecoder{
+		r:         br,
+		remaining: int64(binary.LittleEndian.Uint32(header[4:8])),
+		crc:       fitCRC(0, header), // the file CRC covers the header as well as the data section
+	}
+
+	track := Track{Name: "FIT activity"}
+	segment := Segment{}
+	points := 0
+	for {
+		msg, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg.GlobalNum {
+		case fitMesgNumRecord:
+			if wp, ok := fitRecordToWaypoint(msg); ok {
+				points++
+				if points > MaxStreamPoints {
+					return nil, fmt.Errorf("FIT file exceeds the maximum of %d points", MaxStreamPoints)
+				}
+				segment.Points = append(segment.Points, wp)
+			}
+		case fitMesgNumEvent:
+			event, _ := fitValue(msg, fitFieldEvent)
+			eventType, _ := fitValue(msg, fitFieldEventType)
+			if event == fitEventTimer && (eventType == fitEventTypeStop || eventType == fitEventTypeStopAll) && len(segment.Points) > 0 {
+				track.Segments = append(track.Segments, segment)
+				segment = Segment{}
+			}
+		}
+	}
+	if len(segment.Points) > 0 {
+		track.Segments = append(track.Segments, segment)
+	}
+
+	// Verify the file CRC when present
+	crcBytes := make([]byte, 2)
+	if _, err := io.ReadFull(br, crcBytes); err == nil {
+		if expected := binary.LittleEndian.Uint16(crcBytes); expected != 0 && expected != decoder.crc {
+			return nil, fmt.Errorf("FIT file CRC mismatch")
+		}
+	}
+
+	if len(track.Segments) == 0 {
+		return nil, fmt.Errorf("no GPS records found in FIT file")
+	}
+
+	return &GPX{Tracks: []Track{track}}, nil
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// CleaningOptions configures how track and route points are cleaned before features are computed.
+// Cleaning only affects the derived data, the uploaded file is stored unchanged.
+type CleaningOptions struct {
+	Enabled bool `json:"enabled"`
+	// DropDuplicates removes points with the same position and timestamp as the previous point
+	DropDuplicates bool `json:"drop_duplicates"`
+	// MaxSpeedKmh rejects points that could only be reached faster than this from the previous point (0 disables)
+	MaxSpeedKmh float64 `json:"max_speed_kmh"`
+	// MaxConsecutiveOutliers is the number of rejected points after which the track is assumed to have really moved
+	MaxConsecutiveOutliers int `json:"max_consecutive_outliers"`
+	// MaxUntimedJumpMeters rejects points without a usable timestamp that are farther than this from the previous
+	// point, in lines that otherwise have timestamps (0 disables)
+	MaxUntimedJumpMeters float64 `json:"max_untimed_jump_meters"`
+	// ElevationSpikeMeters plus MaxElevationGrade times the distance is the largest elevation change accepted
+	// between two points; larger changes are clamped (ElevationSpikeMeters 0 disables)
+	ElevationSpikeMeters float64 `json:"elevation_spike_meters"`
+	MaxElevationGrade    float64 `json:"max_elevation_grade"`
+	// DropInvalidTimestamps removes timestamps before MinValidTimestamp or earlier than the previous point
+	DropInvalidTimestamps bool `json:"drop_invalid_timestamps"`
+}
+
+// MinValidTimestamp is the earliest plausible GPS fix time; earlier values come from receivers without a fix
+var MinValidTimestamp = time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultCleaningOptions returns the cleaning settings used when none are configured
+func DefaultCleaningOptions() CleaningOptions {
+	return CleaningOptions{
+		Enabled:                true,
+		DropDuplicates:         true,
+		MaxSpeedKmh:            300,
+		MaxConsecutiveOutliers: 5,
+		MaxUntimedJumpMeters:   1000,
+		ElevationSpikeMeters:   50,
+		MaxElevationGrade:      1.0,
+		DropInvalidTimestamps:  true,
+	}
+}
+
+// TrackMetrics summarizes a sequence of points without any smoothing
+type TrackMetrics struct {
+	PointCount         int      `json:"point_count"`
+	DistanceKm         float64  `json:"distance_km"`
+	MaxSpeedKmh        *float64 `json:"max_speed_kmh,omitempty"`        // fastest speed between two consecutive points
+	ElapsedTimeSeconds *int     `json:"elapsed_time_seconds,omitempty"` // latest minus earliest timestamp
+	ElevationGain      *float64 `json:"elevation_gain,omitempty"`       // sum of all positive elevation changes
+}
+
+// CleaningReport records what the cleaning pipeline removed or changed, with metrics before and after cleaning
+type CleaningReport struct {
+	Options              CleaningOptions `json:"options"`
+	DuplicatePoints      int             `json:"duplicate_points"`
+	SpeedOutliers        int             `json:"speed_outliers"`
+	DistanceOutliers     int             `json:"distance_outliers"`
+	InvalidTimestamps    int             `json:"invalid_timestamps"`
+	OutOfOrderTimestamps int             `json:"out_of_order_timestamps"`
+	ClampedElevations    int             `json:"clamped_elevations"`
+	Raw                  TrackMetrics    `json:"raw"`     // metrics of the uploaded points
+	Cleaned              TrackMetrics    `json:"cleaned"` // metrics of the points used for the route features
+}
+
+// cleanPoint is the last accepted point of the current line
+type cleanPoint struct {
+	Lat, Lon float64
+	Ele      *float64
+	Time     time.Time
+	HasTime  bool
+}
+
+// metricsAccumulator builds TrackMetrics from points fed line by line
+type metricsAccumulator struct {
+	metrics    TrackMetrics
+	distance   float64
+	maxSpeed   float64
+	hasSpeed   bool
+	gain       float64
+	hasEle     bool
+	first      time.Time
+	last       time.Time
+	hasTime    bool
+	prev       *cleanPoint
+	lineKey    [3]int
+	hasLineKey bool
+}
+
+// add records a point; key identifies the track segment or route it belongs to
+func (m *metricsAccumulator) add(key [3]int, point cleanPoint) {
+	m.metrics.PointCount++
+	if !m.hasLineKey || key != m.lineKey {
+		m.prev = nil
+		m.lineKey, m.hasLineKey = key, true
+	}
+
+	if point.HasTime {
+		if !m.hasTime || point.Time.Before(m.first) {
+			m.first = point.Time
+		}
+		if !m.hasTime || point.Time.After(m.last) {
+			m.last = point.Time
+		}
+		m.hasTime = true
+	}
+
+	if prev := m.prev; prev != nil {
+		d := HaversineDistance(prev.Lat, prev.Lon, point.Lat, point.Lon)
+		m.distance += d
+		if prev.HasTime && point.HasTime {
+			if dt := point.Time.Sub(prev.Time).Seconds(); dt > 0 {
+				speed := d / dt * 3.6
+				if !m.hasSpeed || speed > m.maxSpeed {
+					m.maxSpeed, m.hasSpeed = speed, true
+				}
+			}
+		}
+		if prev.Ele != nil && point.Ele != nil {
+			m.hasEle = true
+			if delta := *point.Ele - *prev.Ele; delta > 0 {
+				m.gain += delta
+			}
+		}
+	}
+	m.prev = &point
+}
+
+// result returns the accumulated metrics
+func (m *metricsAccumulator) result() TrackMetrics {
+	metrics := m.metrics
+	metrics.DistanceKm = RoundTo(m.distance/1000, 3)
+	if m.hasSpeed {
+		speed := RoundTo(m.maxSpeed, 2)
+		metrics.MaxSpeedKmh = &speed
+	}
+	if m.hasTime {
+		elapsed := int(m.last.Sub(m.first).Seconds())
+		metrics.ElapsedTimeSeconds = &elapsed
+	}
+	if m.hasEle {
+		gain := RoundTo(m.gain, 1)
+		metrics.ElevationGain = &gain
+	}
+	return metrics
+}
+
+// TrackCleaner is a PointConsumer that removes GPS noise from track and route points before passing them on.
+// Standalone waypoints are forwarded unchanged. Point indexes are renumbered so downstream consumers
+// still see every line start at index 0.
+type TrackCleaner struct {
+	options CleaningOptions
+	next    []PointConsumer
+	report  CleaningReport
+
+	raw     metricsAccumulator
+	cleaned metricsAccumulator
+
+	lineKey    [3]int
+	hasLineKey bool
+	last       *cleanPoint
+	lastTimed  *cleanPoint
+	outIndex   int
+	rejected   int
+}
+
+// NewTrackCleaner creates a cleaner that forwards accepted points to the given consumers
+func NewTrackCleaner(options CleaningOptions, next ...PointConsumer) *TrackCleaner {
+	return &TrackCleaner{
+		options: options,
+		next:    next,
+		report:  CleaningReport{Options: options},
+	}
+}
+
+// ConsumePoint cleans a single point and forwards it unless it is rejected
+func (c *TrackCleaner) ConsumePoint(p *StreamPoint) error {
+	if p.Kind == PointKindWaypoint {
+		return c.forward(p)
+	}
+
+	key := [3]int{int(p.Kind), p.TrackIndex, p.SegmentIndex}
+	if !c.hasLineKey || key != c.lineKey || p.PointIndex == 0 {
+		c.lineKey, c.hasLineKey = key, true
+		c.last = nil
+		c.lastTimed = nil
+		c.outIndex = 0
+		c.rejected = 0
+	}
+
+	point := cleanPoint{Lat: p.Lat, Lon: p.Lon, Ele: p.Ele}
+	if p.Time != nil {
+		if t, err := time.Parse(time.RFC3339, *p.Time); err == nil {
+			point.Time, point.HasTime = t, true
+		}
+	}
+	c.raw.add(key, point)
+
+	if !c.options.Enabled {
+		return c.accept(p, key, point)
+	}
+
+	cleaned := *p
+	if c.options.DropInvalidTimestamps && point.HasTime {
+		if point.Time.Before(MinValidTimestamp) {
+			c.report.InvalidTimestamps++
+			point.HasTime, cleaned.Time = false, nil
+		} else if c.last != nil && c.last.HasTime && point.Time.Before(c.last.Time) {
+			c.report.OutOfOrderTimestamps++
+			point.HasTime, cleaned.Time = false, nil
+		}
+	}
+
+	if c.last != nil {
+		last := c.last
+		distance := HaversineDistance(last.Lat, last.Lon, point.Lat, point.Lon)
+
+		if c.options.DropDuplicates && distance == 0 && last.HasTime == point.HasTime && (!point.HasTime || point.Time.Equal(last.Time)) {
+			c.report.DuplicatePoints++
+			return nil
+		}
+
+		// The speed is checked against the last accepted point with a timestamp, so a point whose timestamp
+		// was dropped cannot hide a jump from the check
+		if timed := c.lastTimed; c.options.MaxSpeedKmh > 0 && timed != nil && point.HasTime {
+			// Fixes logged within the same second are compared as if one second apart
+			timedDistance := HaversineDistance(timed.Lat, timed.Lon, point.Lat, point.Lon)
+			seconds := math.Max(point.Time.Sub(timed.Time).Seconds(), 1)
+			if timedDistance/seconds*3.6 > c.options.MaxSpeedKmh && c.rejectOutlier() {
+				c.report.SpeedOutliers++
+				return nil
+			}
+		}
+
+		// Without a timestamp only the distance is left to tell a bogus fix from a real one
+		if c.options.MaxUntimedJumpMeters > 0 && c.lastTimed != nil && !point.HasTime && distance > c.options.MaxUntimedJumpMeters {
+			if c.rejectOutlier() {
+				c.report.DistanceOutliers++
+				return nil
+			}
+		}
+
+		if c.options.ElevationSpikeMeters > 0 && last.Ele != nil && point.Ele != nil {
+			limit := c.options.ElevationSpikeMeters + distance*c.options.MaxElevationGrade
+			if delta := *point.Ele - *last.Ele; math.Abs(delta) > limit {
+				ele := *last.Ele + math.Copysign(limit, delta)
+				point.Ele, cleaned.Ele = &ele, &ele
+				c.report.ClampedElevations++
+			}
+		}
+	}
+	c.rejected = 0
+
+	return c.accept(&cleaned, key, point)
+}
+
+// rejectOutlier counts an outlier and reports whether to drop it. After too many outliers in a row the
+// previous point is assumed to have been the bad one and the track continues from the current point.
+func (c *TrackCleaner) rejectOutlier() bool {
+	c.rejected++
+	if c.rejected <= c.options.MaxConsecutiveOutliers {
+		return true
+	}
+	c.rejected = 0
+	return false
+}
+
+// accept records a point as the new reference and forwards it with the next index of its line
+func (c *TrackCleaner) accept(p *StreamPoint, key [3]int, point cleanPoint) error {
+	c.last = &point
+	if point.HasTime {
+		c.lastTimed = &point
+	}
+	c.cleaned.add(key, point)
+
+	out := *p
+	out.PointIndex = c.outIndex
+	c.outIndex++
+	return c.forward(&out)
+}
+
+// forward passes a point to all downstream consumers
+func (c *TrackCleaner) forward(p *StreamPoint) error {
+	for _, consumer := range c.next {
+		if err := consumer.ConsumePoint(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish finishes all downstream consumers
+func (c *TrackCleaner) Finish() error {
+	for _, consumer := range c.next {
+		if err := consumer.Finish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report returns what was removed or changed, with raw and cleaned metrics
+func (c *TrackCleaner) Report() *CleaningReport {
+	report := c.report
+	report.Raw = c.raw.result()
+	report.Cleaned = c.cleaned.result()
+	return &report
+}
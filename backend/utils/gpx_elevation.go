@@ -0,0 +1,88 @@
+package utils
+
+import "math"
+
+const (
+	// ElevationSmoothingWindow is the number of samples in the centered moving average applied before accumulating ascent/descent
+	ElevationSmoothingWindow = 5
+	// ElevationHysteresisMeters is the minimum elevation change that counts towards ascent/descent
+	ElevationHysteresisMeters = 3.0
+)
+
+// ElevationStats represents cumulative elevation information for a sequence of points
+type ElevationStats struct {
+	TotalAscent  float64 // in meters
+	TotalDescent float64 // in meters
+	MinElevation float64 // in meters
+	MaxElevation float64 // in meters
+}
+
+// SmoothElevations applies a centered moving average to reduce GPS/barometer noise
+func SmoothElevations(elevations []float64, window int) []float64 {
+	if window <= 1 || len(elevations) < 3 {
+		return append([]float64(nil), elevations...)
+	}
+
+	half := window / 2
+	smoothed := make([]float64, len(elevations))
+	for i := range elevations {
+		start := i - half
+		if start < 0 {
+			start = 0
+		}
+		end := i + half
+		if end > len(elevations)-1 {
+			end = len(elevations) - 1
+		}
+
+		sum := 0.0
+		for j := start; j <= end; j++ {
+			sum += elevations[j]
+		}
+		smoothed[i] = sum / float64(end-start+1)
+	}
+
+	return smoothed
+}
+
+// AccumulateElevationChange sums ascent and descent, ignoring changes smaller than the hysteresis threshold
+func AccumulateElevationChange(elevations []float64, threshold float64) (ascent, descent float64) {
+	if len(elevations) == 0 {
+		return 0, 0
+	}
+
+	reference := elevations[0]
+	for _, ele := range elevations[1:] {
+		delta := ele - reference
+		if delta >= threshold {
+			ascent += delta
+			reference = ele
+		} else if -delta >= threshold {
+			descent += -delta
+			reference = ele
+		}
+	}
+
+	return ascent, descent
+}
+
+// CalculateElevationStats computes smoothed total ascent/descent and raw min/max elevation
+func CalculateElevationStats(elevations []float64) *ElevationStats {
+	if len(elevations) == 0 {
+		return nil
+	}
+
+	stats := &ElevationStats{
+		MinElevation: math.Inf(1),
+		MaxElevation: math.Inf(-1),
+	}
+	for _, ele := range elevations {
+		stats.MinElevation = math.Min(stats.MinElevation, ele)
+		stats.MaxElevation = math.Max(stats.MaxElevation, ele)
+	}
+
+	smoothed := SmoothElevations(elevations, ElevationSmoothingWindow)
+	stats.TotalAscent, stats.TotalDescent = AccumulateElevationChange(smoothed, ElevationHysteresisMeters)
+
+	return stats
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// GPXNamespace is the GPX 1.1 schema namespace
+	GPXNamespace = "http://www.topografix.com/GPX/1/1"
+	// GarminTPXNamespace is the Garmin TrackPointExtension v2 namespace used for sensor data
+	GarminTPXNamespace = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+	// GPXCreator is written to the creator attribute of generated GPX files
+	GPXCreator = "gpxbase"
+)
+
+// MarshalXML writes sensor channels as <power> plus a Garmin TrackPointExtension v2 block
+func (s *SensorData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if s.IsEmpty() {
+		return nil
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	encodeValue := func(name string, value *float64) error {
+		if value == nil {
+			return nil
+		}
+		return e.EncodeElement(strconv.FormatFloat(*value, 'f', -1, 64), xml.StartElement{Name: xml.Name{Local: name}})
+	}
+
+	// Power has no field in TrackPointExtension; the un-namespaced <power> element is the common convention
+	if err := encodeValue("power", s.Power); err != nil {
+		return err
+	}
+
+	if s.Temperature != nil || s.HeartRate != nil || s.Cadence != nil || s.Speed != nil {
+		tpx := xml.StartElement{Name: xml.Name{Local: "gpxtpx:TrackPointExtension"}}
+		if err := e.EncodeToken(tpx); err != nil {
+			return err
+		}
+		// Element order follows the TrackPointExtension v2 schema
+		for _, field := range []struct {
+			name  string
+			value *float64
+		}{
+			{"gpxtpx:atemp", s.Temperature},
+			{"gpxtpx:hr", s.HeartRate},
+			{"gpxtpx:cad", s.Cadence},
+			{"gpxtpx:speed", s.Speed},
+		} {
+			if err := encodeValue(field.name, field.value); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(tpx.End()); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// EncodeGPX writes a GPX 1.1 document for the given track representation
+func EncodeGPX(w io.Writer, gpx *GPX) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write GPX header: %w", err)
+	}
+
+	doc := *gpx
+	doc.Version = "1.1"
+	if doc.Creator == "" {
+		doc.Creator = GPXCreator
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	start := xml.StartElement{
+		Name: xml.Name{Local: "gpx"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: GPXNamespace},
+			{Name: xml.Name{Local: "xmlns:gpxtpx"}, Value: GarminTPXNamespace},
+		},
+	}
+	if err := encoder.EncodeElement(&doc, start); err != nil {
+		return fmt.Errorf("failed to encode GPX: %w", err)
+	}
+	if err := encoder.Flush(); err != nil {
+		return fmt.Errorf("failed to encode GPX: %w", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,11 @@ import (
 )
 
 func GenerateGPXFileName(routeName string, routeID string) string {
+	return GenerateRouteFileName(routeName, routeID, "gpx")
+}
+
+// GenerateRouteFileName builds a download file name from the route name with the given extension
+func GenerateRouteFileName(routeName string, routeID string, extension string) string {
 	replacer := strings.NewReplacer(" ", "_", "　", "_")
 	name := replacer.Replace(routeName)
 
@@ -22,5 +27,5 @@ func GenerateGPXFileName(routeName string, routeID string) string {
 		name = routeID
 	}
 
-	return name + ".gpx"
+	return name + "." + extension
 }
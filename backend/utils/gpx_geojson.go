@@ -1,19 +1,19 @@
 package utils
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"strings"
 	"time"
 )
 
 // GPX represents the GPX XML structure
 type GPX struct {
-	XMLName xml.Name `xml:"gpx"`
-	Tracks  []Track  `xml:"trk"`
-	Routes  []Route  `xml:"rte"`
+	XMLName   xml.Name   `xml:"gpx"`
+	Version   string     `xml:"version,attr,omitempty"`
+	Creator   string     `xml:"creator,attr,omitempty"`
 	Waypoints []Waypoint `xml:"wpt"`
+	Routes    []Route    `xml:"rte"`
+	Tracks    []Track    `xml:"trk"`
 }
 
 // Track represents a GPX track
@@ -35,11 +35,14 @@ type Segment struct {
 
 // Waypoint represents a GPS point (used in tracks, routes, and standalone waypoints)
 type Waypoint struct {
-	Lat  float64 `xml:"lat,attr"`
-	Lon  float64 `xml:"lon,attr"`
-	Ele  *float64 `xml:"ele,omitempty"`
-	Time *string  `xml:"time,omitempty"`
-	Name *string  `xml:"name,omitempty"`
+	Lat         float64     `xml:"lat,attr"`
+	Lon         float64     `xml:"lon,attr"`
+	Ele         *float64    `xml:"ele,omitempty"`
+	Time        *string     `xml:"time,omitempty"`
+	Name        *string     `xml:"name,omitempty"`
+	Description *string     `xml:"desc,omitempty"`
+	Symbol      *string     `xml:"sym,omitempty"`
+	Sensors     *SensorData `xml:"extensions,omitempty"`
 }
 
 // GeoJSON structures
@@ -59,252 +62,244 @@ type Geometry struct {
 	Coordinates interface{} `json:"coordinates"`
 }
 
-// ParseGPX parses GPX content and returns the GPX structure
-func ParseGPX(content []byte) (*GPX, error) {
-	var gpx GPX
-	err := xml.Unmarshal(content, &gpx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse GPX: %w", err)
-	}
-	return &gpx, nil
+// GeoJSONBuilder is a PointConsumer that assembles GeoJSON features from streamed points.
+// Tracks are emitted first, then routes, then waypoints, regardless of document order.
+type GeoJSONBuilder struct {
+	tracks    []Feature
+	routes    []Feature
+	waypoints []Feature
 }
 
-// ConvertGPXToGeoJSON converts GPX data to GeoJSON format
-func ConvertGPXToGeoJSON(gpx *GPX) (*GeoJSON, error) {
-	geoJSON := &GeoJSON{
-		Type:     "FeatureCollection",
-		Features: []Feature{},
-	}
-
-	// Convert tracks to LineString features
-	for i, track := range gpx.Tracks {
-		for j, segment := range track.Segments {
-			if len(segment.Points) == 0 {
-				continue
-			}
-
-			coordinates := make([][]float64, 0, len(segment.Points))
-			for _, point := range segment.Points {
-				// GeoJSON uses [longitude, latitude] order
-				coord := []float64{point.Lon, point.Lat}
-				if point.Ele != nil {
-					coord = append(coord, *point.Ele)
-				}
-				coordinates = append(coordinates, coord)
-			}
+// NewGeoJSONBuilder creates an empty GeoJSON builder
+func NewGeoJSONBuilder() *GeoJSONBuilder {
+	return &GeoJSONBuilder{}
+}
 
-			if len(coordinates) > 0 {
-				feature := Feature{
-					Type: "Feature",
-					Properties: map[string]interface{}{
-						"name": track.Name,
-						"type": "track",
-						"track_index": i,
-						"segment_index": j,
-					},
-					Geometry: Geometry{
-						Type:        "LineString",
-						Coordinates: coordinates,
-					},
-				}
-				geoJSON.Features = append(geoJSON.Features, feature)
-			}
-		}
+// pointCoordinates returns GeoJSON [longitude, latitude(, elevation)] coordinates for a point
+func pointCoordinates(point Waypoint) []float64 {
+	// GeoJSON uses [longitude, latitude] order
+	coord := []float64{point.Lon, point.Lat}
+	if point.Ele != nil {
+		coord = append(coord, *point.Ele)
 	}
+	return coord
+}
 
-	// Convert routes to LineString features
-	for i, route := range gpx.Routes {
-		if len(route.Points) == 0 {
-			continue
-		}
-
-		coordinates := make([][]float64, 0, len(route.Points))
-		for _, point := range route.Points {
-			coord := []float64{point.Lon, point.Lat}
-			if point.Ele != nil {
-				coord = append(coord, *point.Ele)
-			}
-			coordinates = append(coordinates, coord)
-		}
-
-		if len(coordinates) > 0 {
-			feature := Feature{
-				Type: "Feature",
-				Properties: map[string]interface{}{
-					"name": route.Name,
-					"type": "route",
-					"route_index": i,
-				},
-				Geometry: Geometry{
-					Type:        "LineString",
-					Coordinates: coordinates,
-				},
-			}
-			geoJSON.Features = append(geoJSON.Features, feature)
-		}
+// appendToLine starts a new LineString feature when a segment/route begins and appends the point to it
+func appendToLine(features []Feature, p *StreamPoint, properties map[string]interface{}) []Feature {
+	if p.PointIndex == 0 || len(features) == 0 {
+		features = append(features, Feature{
+			Type:       "Feature",
+			Properties: properties,
+			Geometry: Geometry{
+				Type:        "LineString",
+				Coordinates: [][]float64{},
+			},
+		})
 	}
 
-	// Convert waypoints to Point features
-	for i, waypoint := range gpx.Waypoints {
-		coord := []float64{waypoint.Lon, waypoint.Lat}
-		if waypoint.Ele != nil {
-			coord = append(coord, *waypoint.Ele)
-		}
+	last := &features[len(features)-1]
+	last.Geometry.Coordinates = append(last.Geometry.Coordinates.([][]float64), pointCoordinates(p.Waypoint))
+	return features
+}
 
+// ConsumePoint adds a streamed point to the feature it belongs to
+func (b *GeoJSONBuilder) ConsumePoint(p *StreamPoint) error {
+	switch p.Kind {
+	case PointKindTrack:
+		// Convert track segments to LineString features
+		b.tracks = appendToLine(b.tracks, p, map[string]interface{}{
+			"name":          p.TrackName,
+			"type":          "track",
+			"track_index":   p.TrackIndex,
+			"segment_index": p.SegmentIndex,
+		})
+	case PointKindRoute:
+		// Convert routes to LineString features
+		b.routes = appendToLine(b.routes, p, map[string]interface{}{
+			"name":        p.TrackName,
+			"type":        "route",
+			"route_index": p.TrackIndex,
+		})
+	case PointKindWaypoint:
+		// Convert waypoints to Point features
 		properties := map[string]interface{}{
-			"type": "waypoint",
-			"waypoint_index": i,
+			"type":           "waypoint",
+			"waypoint_index": p.PointIndex,
+		}
+		if p.Name != nil {
+			properties["name"] = *p.Name
 		}
-		if waypoint.Name != nil {
-			properties["name"] = *waypoint.Name
+		if p.Time != nil {
+			properties["time"] = *p.Time
 		}
-		if waypoint.Time != nil {
-			properties["time"] = *waypoint.Time
+		if p.Description != nil {
+			properties["description"] = *p.Description
+		}
+		if p.Symbol != nil {
+			properties["symbol"] = *p.Symbol
 		}
 
-		feature := Feature{
+		b.waypoints = append(b.waypoints, Feature{
 			Type:       "Feature",
 			Properties: properties,
 			Geometry: Geometry{
 				Type:        "Point",
-				Coordinates: coord,
+				Coordinates: pointCoordinates(p.Waypoint),
 			},
-		}
-		geoJSON.Features = append(geoJSON.Features, feature)
+		})
 	}
+	return nil
+}
 
-	if len(geoJSON.Features) == 0 {
-		return nil, fmt.Errorf("no valid GPS data found in GPX file")
+// Finish fails if no usable GPS data was found
+func (b *GeoJSONBuilder) Finish() error {
+	if len(b.tracks)+len(b.routes)+len(b.waypoints) == 0 {
+		return fmt.Errorf("no valid GPS data found in GPX file")
 	}
-
-	return geoJSON, nil
+	return nil
 }
 
-// ProcessGPXToGeoJSON processes GPX content and returns GeoJSON string
-func ProcessGPXToGeoJSON(content []byte) (string, error) {
-	// Parse GPX
-	gpx, err := ParseGPX(content)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse GPX: %w", err)
-	}
+// GeoJSON returns the assembled FeatureCollection
+func (b *GeoJSONBuilder) GeoJSON() *GeoJSON {
+	features := make([]Feature, 0, len(b.tracks)+len(b.routes)+len(b.waypoints))
+	features = append(features, b.tracks...)
+	features = append(features, b.routes...)
+	features = append(features, b.waypoints...)
 
-	// Convert to GeoJSON
-	geoJSON, err := ConvertGPXToGeoJSON(gpx)
-	if err != nil {
-		return "", fmt.Errorf("failed to convert to GeoJSON: %w", err)
+	return &GeoJSON{
+		Type:     "FeatureCollection",
+		Features: features,
 	}
+}
 
-	// Marshal to JSON string
-	jsonBytes, err := json.Marshal(geoJSON)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal GeoJSON: %w", err)
-	}
+// GPXStats represents calculated statistics from GPX data
+type GPXStats struct {
+	StartTime          *time.Time `json:"start_time"`
+	EndTime            *time.Time `json:"end_time"`
+	Duration           *int       `json:"duration_minutes"`         // in minutes
+	AverageSpeed       *float64   `json:"average_speed_kmh"`        // in km/h
+	MaxElevationGain   *float64   `json:"max_elevation_gain"`       // in meters
+	TotalAscent        *float64   `json:"total_ascent"`             // smoothed cumulative gain in meters
+	TotalDescent       *float64   `json:"total_descent"`            // smoothed cumulative loss in meters
+	MinElevation       *float64   `json:"min_elevation"`            // in meters
+	MaxElevation       *float64   `json:"max_elevation"`            // in meters
+	MovingTime         *int       `json:"moving_time_seconds"`      // time spent moving in seconds
+	StoppedTime        *int       `json:"stopped_time_seconds"`     // time spent stopped in seconds
+	ElapsedTime        *int       `json:"elapsed_time_seconds"`     // end time minus start time in seconds
+	MovingAverageSpeed *float64   `json:"moving_average_speed_kmh"` // in km/h, excluding stops
+	MaxSpeed           *float64   `json:"max_speed_kmh"`            // in km/h
+	AvgHeartRate       *float64   `json:"avg_heart_rate"`           // in bpm
+	MaxHeartRate       *float64   `json:"max_heart_rate"`           // in bpm
+	AvgCadence         *float64   `json:"avg_cadence"`              // in rpm/spm
+	AvgPower           *float64   `json:"avg_power"`                // in watts
+	NormalizedPower    *float64   `json:"normalized_power"`         // in watts
+	AvgTemperature     *float64   `json:"avg_temperature"`          // in degrees Celsius
+	MaxSensorSpeed     *float64   `json:"max_sensor_speed_kmh"`     // in km/h, from speed sensor extensions
+}
 
-	return string(jsonBytes), nil
+// statsSample is the compact per-point data retained for statistics
+type statsSample struct {
+	Lat     float64
+	Lon     float64
+	Ele     float64
+	HasEle  bool
+	Time    time.Time
+	HasTime bool
+	Sensor  SensorSample
 }
 
-// ExtractMainLineString extracts the primary LineString from GeoJSON for PostGIS processing
-// This is useful for getting the main track/route path for spatial calculations
-func ExtractMainLineString(geoJSONStr string) (string, error) {
-	var geoJSON GeoJSON
-	err := json.Unmarshal([]byte(geoJSONStr), &geoJSON)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse GeoJSON: %w", err)
-	}
+// GPXStatsCollector is a PointConsumer that gathers the data needed for GPXStats
+type GPXStatsCollector struct {
+	trackPoints []statsSample
+	routePoints []statsSample
+	trackLines  []lineBounds // one per track segment
+	routeLines  []lineBounds // one per route
+}
 
-	// Find the first LineString feature (usually the main track)
-	for _, feature := range geoJSON.Features {
-		if feature.Geometry.Type == "LineString" {
-			// Convert coordinates to WKT LINESTRING format
-			coords, ok := feature.Geometry.Coordinates.([]interface{})
-			if !ok {
-				continue
-			}
+// NewGPXStatsCollector creates an empty statistics collector
+func NewGPXStatsCollector() *GPXStatsCollector {
+	return &GPXStatsCollector{}
+}
 
-			wktCoords := make([]string, 0, len(coords))
-			for _, coord := range coords {
-				coordArray, ok := coord.([]interface{})
-				if !ok || len(coordArray) < 2 {
-					continue
-				}
-				
-				lon, ok1 := coordArray[0].(float64)
-				lat, ok2 := coordArray[1].(float64)
-				if !ok1 || !ok2 {
-					continue
-				}
-				
-				wktCoords = append(wktCoords, fmt.Sprintf("%f %f", lon, lat))
-			}
+// ConsumePoint records track and route points; standalone waypoints are ignored
+func (s *GPXStatsCollector) ConsumePoint(p *StreamPoint) error {
+	if p.Kind == PointKindWaypoint {
+		return nil
+	}
 
-			if len(wktCoords) > 0 {
-				return fmt.Sprintf("LINESTRING(%s)", strings.Join(wktCoords, ", ")), nil
-			}
+	sample := statsSample{Lat: p.Lat, Lon: p.Lon}
+	if p.Ele != nil {
+		sample.Ele = *p.Ele
+		sample.HasEle = true
+	}
+	if p.Time != nil && *p.Time != "" {
+		if t, err := time.Parse(time.RFC3339, *p.Time); err == nil {
+			sample.Time = t
+			sample.HasTime = true
 		}
 	}
-
-	return "", fmt.Errorf("no LineString geometry found in GeoJSON")
+	sample.Sensor = NewSensorSample(p.Sensors)
+	sample.Sensor.Time = sample.Time
+	sample.Sensor.HasTime = sample.HasTime
+
+	if p.Kind == PointKindTrack {
+		s.trackLines = appendLine(s.trackLines, p, len(s.trackPoints))
+		s.trackPoints = append(s.trackPoints, sample)
+	} else {
+		s.routeLines = appendLine(s.routeLines, p, len(s.routePoints))
+		s.routePoints = append(s.routePoints, sample)
+	}
+	return nil
 }
 
-// GPXStats represents calculated statistics from GPX data
-type GPXStats struct {
-	StartTime         *time.Time `json:"start_time"`
-	EndTime           *time.Time `json:"end_time"`
-	Duration          *int       `json:"duration_minutes"`    // in minutes
-	AverageSpeed      *float64   `json:"average_speed_kmh"`   // in km/h
-	MaxElevationGain  *float64   `json:"max_elevation_gain"`  // in meters
+// Finish implements PointConsumer
+func (s *GPXStatsCollector) Finish() error {
+	return nil
 }
 
-// AnalyzeGPXTiming analyzes GPX data and extracts timing and elevation information
-func AnalyzeGPXTiming(content []byte) (*GPXStats, error) {
-	// Parse GPX
-	gpx, err := ParseGPX(content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse GPX: %w", err)
+// Stats calculates timing and elevation statistics from the collected points
+func (s *GPXStatsCollector) Stats() *GPXStats {
+	// Get points from tracks, or from routes if no tracks found
+	allPoints, lines := s.trackPoints, s.trackLines
+	if len(allPoints) == 0 {
+		allPoints, lines = s.routePoints, s.routeLines
 	}
+	return calculateGPXStats(allPoints, lines)
+}
 
+// calculateGPXStats derives timing and elevation statistics from points in document order.
+// Ascent and descent are accumulated per line, so the jump between two segments does not count.
+func calculateGPXStats(allPoints []statsSample, lines []lineBounds) *GPXStats {
 	stats := &GPXStats{}
-	
-	// Collect all points with timestamps
-	var allPoints []Waypoint
-	
-	// Get points from tracks
-	for _, track := range gpx.Tracks {
-		for _, segment := range track.Segments {
-			allPoints = append(allPoints, segment.Points...)
-		}
-	}
-	
-	// Get points from routes (if no tracks found)
-	if len(allPoints) == 0 {
-		for _, route := range gpx.Routes {
-			allPoints = append(allPoints, route.Points...)
-		}
-	}
 
 	if len(allPoints) == 0 {
-		return stats, nil // Return empty stats if no points found
+		return stats // Return empty stats if no points found
 	}
 
 	// Extract timing information
 	var timestamps []time.Time
+	var timedPoints []TimedPoint
+	sensorSamples := make([]SensorSample, 0, len(allPoints))
 	var minEle, maxEle *float64
 	
 	for _, point := range allPoints {
-		// Parse timestamps
-		if point.Time != nil && *point.Time != "" {
-			if t, err := time.Parse(time.RFC3339, *point.Time); err == nil {
-				timestamps = append(timestamps, t)
-			}
+		sensorSamples = append(sensorSamples, point.Sensor)
+
+		// Collect timestamps
+		if point.HasTime {
+			timestamps = append(timestamps, point.Time)
+			timedPoints = append(timedPoints, TimedPoint{Lat: point.Lat, Lon: point.Lon, Time: point.Time})
 		}
 		
 		// Track elevation for max elevation gain
-		if point.Ele != nil {
-			if minEle == nil || *point.Ele < *minEle {
-				minEle = point.Ele
+		if point.HasEle {
+			ele := point.Ele
+			if minEle == nil || ele < *minEle {
+				minEle = &ele
 			}
-			if maxEle == nil || *point.Ele > *maxEle {
-				maxEle = point.Ele
+			if maxEle == nil || ele > *maxEle {
+				maxEle = &ele
 			}
 		}
 	}
@@ -342,5 +337,55 @@ func AnalyzeGPXTiming(content []byte) (*GPXStats, error) {
 		}
 	}
 
-	return stats, nil
+	// Calculate cumulative ascent/descent over smoothed elevations, summed over the lines
+	var totalAscent, totalDescent float64
+	hasElevation := false
+	for _, line := range lines {
+		var elevations []float64
+		for _, point := range allPoints[line.Start:line.End] {
+			if point.HasEle {
+				elevations = append(elevations, point.Ele)
+			}
+		}
+		if eleStats := CalculateElevationStats(elevations); eleStats != nil {
+			totalAscent += eleStats.TotalAscent
+			totalDescent += eleStats.TotalDescent
+			hasElevation = true
+		}
+	}
+	if hasElevation {
+		stats.TotalAscent = &totalAscent
+		stats.TotalDescent = &totalDescent
+		stats.MinElevation = minEle
+		stats.MaxElevation = maxEle
+	}
+
+	// Split elapsed time into moving and stopped time
+	if motion := CalculateMotionStats(timedPoints); motion != nil {
+		movingTime := int(motion.MovingTime)
+		stoppedTime := int(motion.StoppedTime)
+		elapsedTime := int(motion.ElapsedTime)
+		stats.MovingTime = &movingTime
+		stats.StoppedTime = &stoppedTime
+		stats.ElapsedTime = &elapsedTime
+
+		if motion.MovingTime > 0 {
+			movingAvgSpeed := motion.MovingAverageSpeedKMH()
+			maxSpeed := motion.MaxSpeed * 3.6
+			stats.MovingAverageSpeed = &movingAvgSpeed
+			stats.MaxSpeed = &maxSpeed
+		}
+	}
+
+	// Summarize heart rate, cadence, power, temperature and speed channels
+	sensorStats := CalculateSensorStats(sensorSamples)
+	stats.AvgHeartRate = sensorStats.AvgHeartRate
+	stats.MaxHeartRate = sensorStats.MaxHeartRate
+	stats.AvgCadence = sensorStats.AvgCadence
+	stats.AvgPower = sensorStats.AvgPower
+	stats.NormalizedPower = sensorStats.NormalizedPower
+	stats.AvgTemperature = sensorStats.AvgTemperature
+	stats.MaxSensorSpeed = sensorStats.MaxSensorSpeed
+
+	return stats
 }
\ No newline at end of file
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// MinMovingSpeedMPS is the speed (m/s) below which an interval is considered stopped (~1 km/h)
+	MinMovingSpeedMPS = 0.3
+	// SpeedWindowSeconds is the minimum time span used to estimate speed, which suppresses GPS jitter at rest
+	SpeedWindowSeconds = 10.0
+	// StopGapSeconds is the time gap between consecutive points treated as a recording pause
+	StopGapSeconds = 120.0
+)
+
+// TimedPoint is a GPS position with a parsed timestamp
+type TimedPoint struct {
+	Lat  float64
+	Lon  float64
+	Time time.Time
+}
+
+// MotionStats represents moving/stopped time information for a sequence of timed points
+type MotionStats struct {
+	MovingTime     float64 // in seconds
+	StoppedTime    float64 // in seconds
+	ElapsedTime    float64 // in seconds
+	MovingDistance float64 // in meters
+	MaxSpeed       float64 // in m/s
+}
+
+// MovingAverageSpeedKMH returns the average speed over moving time only in km/h
+func (m *MotionStats) MovingAverageSpeedKMH() float64 {
+	if m.MovingTime <= 0 {
+		return 0
+	}
+	return m.MovingDistance / m.MovingTime * 3.6
+}
+
+// windowSpeed estimates the speed at point i from the straight-line displacement
+// over the preceding SpeedWindowSeconds, so random jitter around a stop does not add up
+func windowSpeed(points []TimedPoint, i int) float64 {
+	j := i - 1
+	for j > 0 && points[i].Time.Sub(points[j].Time).Seconds() < SpeedWindowSeconds {
+		j--
+	}
+	dt := points[i].Time.Sub(points[j].Time).Seconds()
+	if dt <= 0 {
+		return 0
+	}
+	return HaversineDistance(points[j].Lat, points[j].Lon, points[i].Lat, points[i].Lon) / dt
+}
+
+// intervalSpeed estimates the speed (m/s) of the interval ending at point i, which decides whether it counts as moving
+func intervalSpeed(points []TimedPoint, i int) float64 {
+	dt := points[i].Time.Sub(points[i-1].Time).Seconds()
+	if dt > StopGapSeconds {
+		// Recording pause or signal loss: judge by the displacement across the gap itself
+		return HaversineDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon) / dt
+	}
+	return windowSpeed(points, i)
+}
+
+// CalculateMotionStats detects stops from point speed and time gaps and splits elapsed time into moving and stopped time
+func CalculateMotionStats(points []TimedPoint) *MotionStats {
+	if len(points) < 2 {
+		return nil
+	}
+
+	stats := &MotionStats{
+		ElapsedTime: points[len(points)-1].Time.Sub(points[0].Time).Seconds(),
+	}
+
+	for i := 1; i < len(points); i++ {
+		dt := points[i].Time.Sub(points[i-1].Time).Seconds()
+		if dt <= 0 {
+			// Skip duplicate or out-of-order timestamps
+			continue
+		}
+		dist := HaversineDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+
+		speed := intervalSpeed(points, i)
+		if speed < MinMovingSpeedMPS {
+			stats.StoppedTime += dt
+			continue
+		}
+
+		stats.MovingTime += dt
+		stats.MovingDistance += dist
+		if dt <= StopGapSeconds {
+			stats.MaxSpeed = math.Max(stats.MaxSpeed, speed)
+		}
+	}
+
+	// Timestamps out of order can make the summed intervals exceed the overall span
+	if stats.ElapsedTime < stats.MovingTime+stats.StoppedTime {
+		stats.ElapsedTime = stats.MovingTime + stats.StoppedTime
+	}
+
+	return stats
+}
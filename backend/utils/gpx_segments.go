@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// MinSegmentPoints is the minimum number of points a segment needs to become part of the route geometry
+const MinSegmentPoints = 2
+
+// lineBounds marks the samples of one track segment or route within the collected points
+type lineBounds struct {
+	Name         string
+	TrackIndex   int
+	SegmentIndex int
+	Start        int // index of the first sample
+	End          int // index after the last sample
+}
+
+// SegmentStats is the per-segment breakdown of a route, in the same order as the parts of its geometry
+type SegmentStats struct {
+	Index        int        `json:"index"`
+	Name         string     `json:"name,omitempty"`
+	TrackIndex   int        `json:"track_index"`
+	SegmentIndex int        `json:"segment_index"`
+	PointCount   int        `json:"point_count"`
+	DistanceKm   float64    `json:"distance_km"`
+	TotalAscent  *float64   `json:"total_ascent,omitempty"`
+	TotalDescent *float64   `json:"total_descent,omitempty"`
+	MinElevation *float64   `json:"min_elevation,omitempty"`
+	MaxElevation *float64   `json:"max_elevation,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	ElapsedTime  *int       `json:"elapsed_time_seconds,omitempty"`
+	MovingTime   *int       `json:"moving_time_seconds,omitempty"`
+}
+
+// appendLine records the start of a new line when a segment or route begins
+func appendLine(lines []lineBounds, p *StreamPoint, start int) []lineBounds {
+	if p.PointIndex == 0 || len(lines) == 0 {
+		lines = append(lines, lineBounds{
+			Name:         p.TrackName,
+			TrackIndex:   p.TrackIndex,
+			SegmentIndex: p.SegmentIndex,
+			Start:        start,
+		})
+	}
+	lines[len(lines)-1].End = start + 1
+	return lines
+}
+
+// geometryLines returns the samples and lines that make up the route geometry: track segments,
+// or routes if there are no tracks. Lines with fewer than MinSegmentPoints points are dropped.
+func (s *GPXStatsCollector) geometryLines() ([]statsSample, []lineBounds) {
+	samples, lines := s.trackPoints, s.trackLines
+	if len(samples) == 0 {
+		samples, lines = s.routePoints, s.routeLines
+	}
+
+	kept := make([]lineBounds, 0, len(lines))
+	for _, line := range lines {
+		if line.End-line.Start >= MinSegmentPoints {
+			kept = append(kept, line)
+		}
+	}
+	return samples, kept
+}
+
+// Geometry returns the route as a MultiLineString with one part per segment, or nil when no segment has enough points.
+// When only some points have an elevation, missing values are filled from the nearest earlier (or first) known value
+// so every coordinate has the same dimension.
+func (s *GPXStatsCollector) Geometry() *Geometry {
+	samples, lines := s.geometryLines()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	// Seed with the first known elevation so leading points without one get a sensible value
+	hasEle := false
+	lastEle := 0.0
+	for _, line := range lines {
+		for _, sample := range samples[line.Start:line.End] {
+			if sample.HasEle && !hasEle {
+				hasEle, lastEle = true, sample.Ele
+			}
+		}
+	}
+
+	coordinates := make([][][]float64, 0, len(lines))
+	for _, line := range lines {
+		coords := make([][]float64, 0, line.End-line.Start)
+		for _, sample := range samples[line.Start:line.End] {
+			coord := []float64{sample.Lon, sample.Lat}
+			if hasEle {
+				if sample.HasEle {
+					lastEle = sample.Ele
+				}
+				coord = append(coord, lastEle)
+			}
+			coords = append(coords, coord)
+		}
+		coordinates = append(coordinates, coords)
+	}
+
+	return &Geometry{Type: "MultiLineString", Coordinates: coordinates}
+}
+
+// PointTimes returns the timestamp of every coordinate of Geometry, in the same order, with nil for points without one.
+// It returns nil when no point has a timestamp.
+func (s *GPXStatsCollector) PointTimes() []*time.Time {
+	samples, lines := s.geometryLines()
+
+	var times []*time.Time
+	hasTime := false
+	for _, line := range lines {
+		for i := line.Start; i < line.End; i++ {
+			if samples[i].HasTime {
+				hasTime = true
+				times = append(times, &samples[i].Time)
+			} else {
+				times = append(times, nil)
+			}
+		}
+	}
+	if !hasTime {
+		return nil
+	}
+	return times
+}
+
+// PointHeartRates returns the heart rate of every coordinate of Geometry, in the same order, with nil for points without one.
+// It returns nil when no point has a heart rate.
+func (s *GPXStatsCollector) PointHeartRates() []*float64 {
+	samples, lines := s.geometryLines()
+
+	var heartRates []*float64
+	hasHeartRate := false
+	for _, line := range lines {
+		for i := line.Start; i < line.End; i++ {
+			if hr := samples[i].Sensor.HeartRate; !math.IsNaN(hr) {
+				hasHeartRate = true
+				heartRates = append(heartRates, &samples[i].Sensor.HeartRate)
+			} else {
+				heartRates = append(heartRates, nil)
+			}
+		}
+	}
+	if !hasHeartRate {
+		return nil
+	}
+	return heartRates
+}
+
+// Segments calculates distance, elevation and timing for every part of the route geometry
+func (s *GPXStatsCollector) Segments() []SegmentStats {
+	samples, lines := s.geometryLines()
+
+	segments := make([]SegmentStats, 0, len(lines))
+	for i, line := range lines {
+		points := samples[line.Start:line.End]
+
+		distance := 0.0
+		for j := 1; j < len(points); j++ {
+			distance += HaversineDistance(points[j-1].Lat, points[j-1].Lon, points[j].Lat, points[j].Lon)
+		}
+
+		stats := calculateGPXStats(points, []lineBounds{{Start: 0, End: len(points)}})
+		segments = append(segments, SegmentStats{
+			Index:        i,
+			Name:         line.Name,
+			TrackIndex:   line.TrackIndex,
+			SegmentIndex: line.SegmentIndex,
+			PointCount:   len(points),
+			DistanceKm:   distance / 1000,
+			TotalAscent:  stats.TotalAscent,
+			TotalDescent: stats.TotalDescent,
+			MinElevation: stats.MinElevation,
+			MaxElevation: stats.MaxElevation,
+			StartTime:    stats.StartTime,
+			EndTime:      stats.EndTime,
+			ElapsedTime:  stats.ElapsedTime,
+			MovingTime:   stats.MovingTime,
+		})
+	}
+	return segments
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"encoding/xml"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// NormalizedPowerWindowSeconds is the rolling average window used for normalized power
+	NormalizedPowerWindowSeconds = 30
+	// MaxPowerGapSeconds is the longest gap between power samples that is filled by holding the last value
+	MaxPowerGapSeconds = 10
+)
+
+// SensorData holds per-point sensor channels parsed from GPX <extensions>.
+// Garmin TrackPointExtension v1/v2, Garmin PowerExtension and the common
+// un-namespaced variants (e.g. <power>) are matched by local element name.
+type SensorData struct {
+	HeartRate   *float64 // in beats per minute
+	Cadence     *float64 // in revolutions/steps per minute
+	Power       *float64 // in watts
+	Temperature *float64 // in degrees Celsius
+	Speed       *float64 // in meters per second
+}
+
+// sensorChannel maps a lower-cased extension element name to the channel it fills
+func (s *SensorData) sensorChannel(name string) **float64 {
+	switch strings.ToLower(name) {
+	case "hr", "heartrate", "heartratebpm":
+		return &s.HeartRate
+	case "cad", "cadence", "runcadence":
+		return &s.Cadence
+	case "power", "powerinwatts", "watts":
+		return &s.Power
+	case "atemp", "temp", "temperature", "wtemp":
+		return &s.Temperature
+	case "speed":
+		return &s.Speed
+	}
+	return nil
+}
+
+// UnmarshalXML walks every element under <extensions> and keeps the recognized sensor values
+func (s *SensorData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var current string
+	depth := 1
+	for depth > 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			current = t.Name.Local
+		case xml.EndElement:
+			depth--
+			current = ""
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || current == "" {
+				continue
+			}
+			channel := s.sensorChannel(current)
+			if channel == nil || *channel != nil {
+				continue
+			}
+			if value, err := strconv.ParseFloat(text, 64); err == nil && !math.IsNaN(value) && !math.IsInf(value, 0) {
+				*channel = &value
+			}
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether no sensor channel was recorded
+func (s *SensorData) IsEmpty() bool {
+	return s == nil || (s.HeartRate == nil && s.Cadence == nil && s.Power == nil && s.Temperature == nil && s.Speed == nil)
+}
+
+// SensorSample is the compact per-point sensor data retained for statistics; missing values are NaN
+type SensorSample struct {
+	Time        time.Time
+	HasTime     bool
+	HeartRate   float64
+	Cadence     float64
+	Power       float64
+	Temperature float64 // in degrees Celsius
+	Speed       float64 // in meters per second
+}
+
+// NewSensorSample converts optional sensor data into a sample with NaN for missing channels
+func NewSensorSample(data *SensorData) SensorSample {
+	sample := SensorSample{
+		HeartRate:   math.NaN(),
+		Cadence:     math.NaN(),
+		Power:       math.NaN(),
+		Temperature: math.NaN(),
+		Speed:       math.NaN(),
+	}
+	if data == nil {
+		return sample
+	}
+	if data.HeartRate != nil {
+		sample.HeartRate = *data.HeartRate
+	}
+	if data.Cadence != nil {
+		sample.Cadence = *data.Cadence
+	}
+	if data.Power != nil {
+		sample.Power = *data.Power
+	}
+	if data.Temperature != nil {
+		sample.Temperature = *data.Temperature
+	}
+	if data.Speed != nil {
+		sample.Speed = *data.Speed
+	}
+	return sample
+}
+
+// SensorStats represents effort summary statistics for a route
+type SensorStats struct {
+	AvgHeartRate    *float64
+	MaxHeartRate    *float64
+	AvgCadence      *float64
+	AvgPower        *float64
+	NormalizedPower *float64
+	AvgTemperature  *float64 // in degrees Celsius
+	MaxSensorSpeed  *float64 // in km/h, as reported by a speed sensor
+}
+
+// CalculateSensorStats computes heart rate, cadence, power, temperature and sensor speed summaries;
+// channels without data stay nil
+func CalculateSensorStats(samples []SensorSample) *SensorStats {
+	stats := &SensorStats{}
+
+	var hrSum, cadSum, powerSum, tempSum float64
+	var hrCount, cadCount, powerCount, tempCount, speedCount int
+	maxHR := math.Inf(-1)
+	maxSpeed := math.Inf(-1)
+	for _, s := range samples {
+		if !math.IsNaN(s.HeartRate) && s.HeartRate > 0 {
+			hrSum += s.HeartRate
+			hrCount++
+			maxHR = math.Max(maxHR, s.HeartRate)
+		}
+		// Zero cadence means coasting/standing and would skew the average
+		if !math.IsNaN(s.Cadence) && s.Cadence > 0 {
+			cadSum += s.Cadence
+			cadCount++
+		}
+		if !math.IsNaN(s.Power) && s.Power >= 0 {
+			powerSum += s.Power
+			powerCount++
+		}
+		if !math.IsNaN(s.Temperature) {
+			tempSum += s.Temperature
+			tempCount++
+		}
+		if !math.IsNaN(s.Speed) && s.Speed >= 0 {
+			maxSpeed = math.Max(maxSpeed, s.Speed)
+			speedCount++
+		}
+	}
+
+	if hrCount > 0 {
+		avgHR := hrSum / float64(hrCount)
+		stats.AvgHeartRate = &avgHR
+		stats.MaxHeartRate = &maxHR
+	}
+	if cadCount > 0 {
+		avgCad := cadSum / float64(cadCount)
+		stats.AvgCadence = &avgCad
+	}
+	if powerCount > 0 {
+		avgPower := powerSum / float64(powerCount)
+		stats.AvgPower = &avgPower
+		stats.NormalizedPower = CalculateNormalizedPower(samples)
+	}
+	if tempCount > 0 {
+		avgTemp := tempSum / float64(tempCount)
+		stats.AvgTemperature = &avgTemp
+	}
+	if speedCount > 0 {
+		maxSpeedKmh := maxSpeed * 3.6
+		stats.MaxSensorSpeed = &maxSpeedKmh
+	}
+
+	return stats
+}
+
+// CalculateNormalizedPower resamples power to 1 Hz, applies a 30 second rolling average and
+// returns the fourth root of the mean of the fourth powers. Requires timestamps.
+func CalculateNormalizedPower(samples []SensorSample) *float64 {
+	var series []float64
+	var last *SensorSample
+	for i := range samples {
+		s := &samples[i]
+		if !s.HasTime || math.IsNaN(s.Power) {
+			continue
+		}
+		if last != nil {
+			gap := int(s.Time.Sub(last.Time).Seconds())
+			if gap <= 0 {
+				continue
+			}
+			// Hold the last value across short gaps; long gaps are pauses and are skipped
+			if gap > MaxPowerGapSeconds {
+				gap = 1
+			}
+			for j := 0; j < gap; j++ {
+				series = append(series, last.Power)
+			}
+		}
+		last = s
+	}
+	if last != nil {
+		series = append(series, last.Power)
+	}
+
+	if len(series) < NormalizedPowerWindowSeconds {
+		return nil
+	}
+
+	var windowSum, fourthSum float64
+	count := 0
+	for i, p := range series {
+		windowSum += p
+		if i >= NormalizedPowerWindowSeconds {
+			windowSum -= series[i-NormalizedPowerWindowSeconds]
+		}
+		if i >= NormalizedPowerWindowSeconds-1 {
+			rolling := windowSum / NormalizedPowerWindowSeconds
+			fourthSum += math.Pow(rolling, 4)
+			count++
+		}
+	}
+
+	np := math.Pow(fourthSum/float64(count), 0.25)
+	return &np
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// MaxStreamPoints is the maximum number of points accepted from a single GPX document
+const MaxStreamPoints = 2000000
+
+// ErrNotGPX is returned when the document root is not a <gpx> element
+var ErrNotGPX = errors.New("document is not a GPX file")
+
+// PointKind identifies which GPX element a streamed point came from
+type PointKind int
+
+const (
+	PointKindWaypoint PointKind = iota // <wpt>
+	PointKindRoute                     // <rtept>
+	PointKindTrack                     // <trkpt>
+)
+
+// StreamPoint is a single point yielded by the streaming parser
+type StreamPoint struct {
+	Waypoint
+	Kind         PointKind
+	TrackIndex   int    // index of the enclosing <trk> or <rte>
+	SegmentIndex int    // index of the enclosing <trkseg>, 0 for routes and waypoints
+	PointIndex   int    // index of the point within its segment, route or waypoint list
+	TrackName    string // name of the enclosing <trk> or <rte>, if it appeared before the points
+}
+
+// PointConsumer receives points from the streaming parser in document order
+type PointConsumer interface {
+	// ConsumePoint is called once per point; returning an error aborts parsing
+	ConsumePoint(p *StreamPoint) error
+
+	// Finish is called once after the whole document has been read successfully
+	Finish() error
+}
+
+// StreamGPX reads a GPX document token by token and yields every point to the consumers in a single pass.
+// Only one point is decoded into memory at a time; consumers decide what to retain.
+func StreamGPX(r io.Reader, consumers ...PointConsumer) error {
+	decoder := xml.NewDecoder(r)
+
+	var stack []string
+	var current StreamPoint
+	waypointIndex := 0
+	trackIndex, routeIndex := -1, -1
+	sawRoot := false
+
+	emit := func(start *xml.StartElement, kind PointKind) error {
+		var wp Waypoint
+		if err := decoder.DecodeElement(&wp, start); err != nil {
+			return fmt.Errorf("failed to decode <%s>: %w", start.Name.Local, err)
+		}
+
+		p := current
+		p.Waypoint = wp
+		p.Kind = kind
+		if kind == PointKindWaypoint {
+			p = StreamPoint{Waypoint: wp, Kind: kind, PointIndex: waypointIndex}
+			waypointIndex++
+		} else {
+			current.PointIndex++
+		}
+
+		for _, consumer := range consumers {
+			if err := consumer.ConsumePoint(&p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse GPX: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			} else if name != "gpx" {
+				return ErrNotGPX
+			} else {
+				sawRoot = true
+			}
+
+			switch {
+			case name == "wpt" && parent == "gpx":
+				if err := emit(&t, PointKindWaypoint); err != nil {
+					return err
+				}
+				continue
+			case name == "rtept" && parent == "rte":
+				if err := emit(&t, PointKindRoute); err != nil {
+					return err
+				}
+				continue
+			case name == "trkpt" && parent == "trkseg":
+				if err := emit(&t, PointKindTrack); err != nil {
+					return err
+				}
+				continue
+			case name == "name" && (parent == "trk" || parent == "rte"):
+				var trackName string
+				if err := decoder.DecodeElement(&trackName, &t); err != nil {
+					return fmt.Errorf("failed to decode <%s> name: %w", parent, err)
+				}
+				current.TrackName = trackName
+				continue
+			case name == "trk" && parent == "gpx":
+				trackIndex++
+				current = StreamPoint{TrackIndex: trackIndex, SegmentIndex: -1}
+			case name == "trkseg" && parent == "trk":
+				current.SegmentIndex++
+				current.PointIndex = 0
+			case name == "rte" && parent == "gpx":
+				routeIndex++
+				current = StreamPoint{TrackIndex: routeIndex}
+			}
+
+			stack = append(stack, name)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if !sawRoot {
+		return ErrNotGPX
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("failed to parse GPX: unexpected end of document inside <%s>", stack[len(stack)-1])
+	}
+
+	for _, consumer := range consumers {
+		if err := consumer.Finish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PointValidator rejects documents with invalid coordinates or too many points
+type PointValidator struct {
+	MaxPoints int
+	count     int
+}
+
+// NewPointValidator creates a validator that accepts at most maxPoints points
+func NewPointValidator(maxPoints int) *PointValidator {
+	return &PointValidator{MaxPoints: maxPoints}
+}
+
+// ConsumePoint validates a single point
+func (v *PointValidator) ConsumePoint(p *StreamPoint) error {
+	v.count++
+	if v.MaxPoints > 0 && v.count > v.MaxPoints {
+		return fmt.Errorf("GPX file exceeds the maximum of %d points", v.MaxPoints)
+	}
+	if math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("invalid latitude %v at point %d", p.Lat, v.count)
+	}
+	if math.IsNaN(p.Lon) || p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("invalid longitude %v at point %d", p.Lon, v.count)
+	}
+	if p.Ele != nil && (math.IsNaN(*p.Ele) || math.IsInf(*p.Ele, 0)) {
+		return fmt.Errorf("invalid elevation at point %d", v.count)
+	}
+	return nil
+}
+
+// Finish implements PointConsumer
+func (v *PointValidator) Finish() error {
+	return nil
+}
+
+// Count returns the number of points validated
+func (v *PointValidator) Count() int {
+	return v.count
+}
+
+// GPXAnalysis holds everything derived from a single streaming pass over a GPX document
+type GPXAnalysis struct {
+	Stats      *GPXStats
+	GeoJSON    *GeoJSON
+	Geometry   *Geometry      // MultiLineString of all track segments (or routes), nil if there is none
+	Segments   []SegmentStats // per-segment breakdown, aligned with the parts of Geometry
+	Waypoints  []Waypoint     // standalone waypoints (points of interest) in document order
+	PointTimes []*time.Time   // timestamp of every coordinate of Geometry, nil if the document has none
+	HeartRates []*float64     // heart rate of every coordinate of Geometry, nil if the document has none
+	PointCount int
+	Cleaning   *CleaningReport // what was removed or changed before the statistics and geometry were computed
+}
+
+// AnalyzeGPXStream validates a GPX document and computes its statistics and GeoJSON in a single pass.
+// Points are validated as read and cleaned with the given options before any feature is computed.
+// Additional consumers can be plugged in to collect more data from the same pass; they receive cleaned points.
+func AnalyzeGPXStream(r io.Reader, cleaning CleaningOptions, extra ...PointConsumer) (*GPXAnalysis, error) {
+	validator := NewPointValidator(MaxStreamPoints)
+	stats := NewGPXStatsCollector()
+	builder := NewGeoJSONBuilder()
+	waypoints := NewWaypointCollector()
+
+	cleaner := NewTrackCleaner(cleaning, append([]PointConsumer{stats, builder, waypoints}, extra...)...)
+	if err := StreamGPX(r, validator, cleaner); err != nil {
+		return nil, err
+	}
+
+	return &GPXAnalysis{
+		Stats:      stats.Stats(),
+		GeoJSON:    builder.GeoJSON(),
+		Geometry:   stats.Geometry(),
+		Segments:   stats.Segments(),
+		Waypoints:  waypoints.Waypoints(),
+		PointTimes: stats.PointTimes(),
+		HeartRates: stats.PointHeartRates(),
+		PointCount: validator.Count(),
+		Cleaning:   cleaner.Report(),
+	}, nil
+}
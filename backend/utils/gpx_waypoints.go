@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxRouteWaypoints is the maximum number of standalone waypoints a route can have
+const MaxRouteWaypoints = 1000
+
+// WaypointCollector keeps the standalone <wpt> elements of a document in order
+type WaypointCollector struct {
+	waypoints []Waypoint
+}
+
+// NewWaypointCollector creates an empty waypoint collector
+func NewWaypointCollector() *WaypointCollector {
+	return &WaypointCollector{}
+}
+
+// ConsumePoint records standalone waypoints; track and route points are ignored.
+// Documents with more than MaxRouteWaypoints waypoints are rejected rather than silently truncated.
+func (c *WaypointCollector) ConsumePoint(p *StreamPoint) error {
+	if p.Kind != PointKindWaypoint {
+		return nil
+	}
+	if len(c.waypoints) >= MaxRouteWaypoints {
+		return fmt.Errorf("file has more than %d waypoints", MaxRouteWaypoints)
+	}
+	wp := p.Waypoint
+	// Sensor extensions are only meaningful for recorded track points
+	wp.Sensors = nil
+	c.waypoints = append(c.waypoints, wp)
+	return nil
+}
+
+// Finish implements PointConsumer
+func (c *WaypointCollector) Finish() error {
+	return nil
+}
+
+// Waypoints returns the collected waypoints
+func (c *WaypointCollector) Waypoints() []Waypoint {
+	return c.waypoints
+}
+
+// WaypointTime parses the timestamp of a waypoint, returning nil when it is missing or not RFC 3339
+func WaypointTime(wp *Waypoint) *time.Time {
+	if wp.Time == nil {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, *wp.Time)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
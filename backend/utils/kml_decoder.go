@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrNotKML is returned when the document root is not a <kml> element
+var ErrNotKML = errors.New("document is not a KML file")
+
+// MaxKMLSize is the maximum uncompressed size of the KML document inside a KMZ archive
+const MaxKMLSize = 256 << 20
+
+// kmlTrack is a <gx:Track> with parallel <when> and <gx:coord> lists
+type kmlTrack struct {
+	When   []string `xml:"when"`
+	Coords []string `xml:"coord"`
+	Arrays []struct {
+		Name   string   `xml:"name,attr"`
+		Values []string `xml:"value"`
+	} `xml:"ExtendedData>SchemaData>SimpleArrayData"`
+}
+
+// kmlSensorChannel maps a gx:SimpleArrayData name to the sensor channel it fills
+func kmlSensorChannel(sensors *SensorData, name string) **float64 {
+	switch strings.ToLower(name) {
+	case "heartrate", "heart_rate", "hr":
+		return &sensors.HeartRate
+	case "cadence", "cad":
+		return &sensors.Cadence
+	case "power", "watts":
+		return &sensors.Power
+	case "temperature", "temp":
+		return &sensors.Temperature
+	case "speed":
+		return &sensors.Speed
+	}
+	return nil
+}
+
+// toSegment converts the gx:Track into a track segment, keeping timestamps and sensor arrays
+func (t *kmlTrack) toSegment() (Segment, error) {
+	var segment Segment
+	for i, coord := range t.Coords {
+		fields := strings.Fields(coord)
+		wp, err := parseKMLCoordinate(fields)
+		if err != nil {
+			return Segment{}, err
+		}
+		if i < len(t.When) {
+			when := strings.TrimSpace(t.When[i])
+			wp.Time = &when
+		}
+
+		sensors := &SensorData{}
+		for _, array := range t.Arrays {
+			channel := kmlSensorChannel(sensors, array.Name)
+			if channel == nil || i >= len(array.Values) {
+				continue
+			}
+			if value, err := strconv.ParseFloat(strings.TrimSpace(array.Values[i]), 64); err == nil {
+				*channel = &value
+			}
+		}
+		if !sensors.IsEmpty() {
+			wp.Sensors = sensors
+		}
+
+		segment.Points = append(segment.Points, wp)
+	}
+	return segment, nil
+}
+
+// parseKMLCoordinate converts a longitude, latitude and optional altitude tuple into a point
+func parseKMLCoordinate(fields []string) (Waypoint, error) {
+	if len(fields) < 2 {
+		return Waypoint{}, fmt.Errorf("invalid KML coordinate %q", strings.Join(fields, ","))
+	}
+	lon, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Waypoint{}, fmt.Errorf("invalid KML longitude %q", fields[0])
+	}
+	lat, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Waypoint{}, fmt.Errorf("invalid KML latitude %q", fields[1])
+	}
+
+	wp := Waypoint{Lat: lat, Lon: lon}
+	if len(fields) > 2 {
+		if ele, err := strconv.ParseFloat(fields[2], 64); err == nil {
+			wp.Ele = &ele
+		}
+	}
+	return wp, nil
+}
+
+// parseKMLCoordinates parses the whitespace separated "lon,lat[,alt]" tuples of a <coordinates> element
+func parseKMLCoordinates(text string) ([]Waypoint, error) {
+	var points []Waypoint
+	for _, tuple := range strings.Fields(text) {
+		wp, err := parseKMLCoordinate(strings.Split(tuple, ","))
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, wp)
+	}
+	return points, nil
+}
+
+// DecodeKML converts the placemarks of a KML document into the internal GPX representation.
+// Each placemark with a <LineString> or <gx:Track> becomes a track (one segment per line or track,
+// so <MultiGeometry> and <gx:MultiTrack> keep their parts); <Point> placemarks become waypoints.
+func DecodeKML(r io.Reader) (*GPX, error) {
+	decoder := xml.NewDecoder(r)
+
+	gpx := &GPX{}
+	var stack []string
+	placemarkName := ""
+	placemarkTrack := -1
+	points := 0
+
+	addSegment := func(segment Segment) error {
+		if len(segment.Points) == 0 {
+			return nil
+		}
+		points += len(segment.Points)
+		if points > MaxStreamPoints {
+			return fmt.Errorf("KML file exceeds the maximum of %d points", MaxStreamPoints)
+		}
+		if placemarkTrack < 0 {
+			gpx.Tracks = append(gpx.Tracks, Track{Name: placemarkName})
+			placemarkTrack = len(gpx.Tracks) - 1
+		}
+		gpx.Tracks[placemarkTrack].Segments = append(gpx.Tracks[placemarkTrack].Segments, segment)
+		return nil
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse KML: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			} else if name != "kml" {
+				return nil, ErrNotKML
+			}
+
+			switch {
+			case name == "Placemark":
+				placemarkName = ""
+				placemarkTrack = -1
+			case name == "name" && parent == "Placemark":
+				if err := decoder.DecodeElement(&placemarkName, &t); err != nil {
+					return nil, fmt.Errorf("failed to decode placemark name: %w", err)
+				}
+				if placemarkTrack >= 0 {
+					gpx.Tracks[placemarkTrack].Name = placemarkName
+				}
+				continue
+			case name == "coordinates" && (parent == "LineString" || parent == "Point"):
+				var text string
+				if err := decoder.DecodeElement(&text, &t); err != nil {
+					return nil, fmt.Errorf("failed to decode <coordinates>: %w", err)
+				}
+				coords, err := parseKMLCoordinates(text)
+				if err != nil {
+					return nil, err
+				}
+				if parent == "LineString" {
+					if err := addSegment(Segment{Points: coords}); err != nil {
+						return nil, err
+					}
+				} else if len(coords) > 0 {
+					wp := coords[0]
+					if placemarkName != "" {
+						name := placemarkName
+						wp.Name = &name
+					}
+					gpx.Waypoints = append(gpx.Waypoints, wp)
+				}
+				continue
+			case name == "Track":
+				var track kmlTrack
+				if err := decoder.DecodeElement(&track, &t); err != nil {
+					return nil, fmt.Errorf("failed to decode <gx:Track>: %w", err)
+				}
+				segment, err := track.toSegment()
+				if err != nil {
+					return nil, err
+				}
+				if err := addSegment(segment); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			stack = append(stack, name)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("failed to parse KML: unexpected end of document inside <%s>", stack[len(stack)-1])
+	}
+	if len(gpx.Tracks) == 0 && len(gpx.Waypoints) == 0 {
+		return nil, fmt.Errorf("no tracks or points found in KML file")
+	}
+
+	return gpx, nil
+}
+
+// DecodeKMZ extracts the main KML document from a KMZ archive and decodes it.
+// The root doc.kml is preferred, otherwise the first .kml entry is used.
+func DecodeKMZ(r io.ReaderAt, size int64) (*GPX, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open KMZ archive: %w", err)
+	}
+
+	var document *zip.File
+	for _, entry := range archive.File {
+		if !strings.EqualFold(path.Ext(entry.Name), ".kml") {
+			continue
+		}
+		if strings.EqualFold(entry.Name, "doc.kml") {
+			document = entry
+			break
+		}
+		if document == nil {
+			document = entry
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("no KML document found in KMZ archive")
+	}
+
+	content, err := document.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from KMZ archive: %w", document.Name, err)
+	}
+	defer content.Close()
+
+	return DecodeKML(io.LimitReader(content, MaxKMLSize))
+}
@@ -0,0 +1,469 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat identifies a format a stored route can be exported to
+type ExportFormat string
+
+const (
+	ExportFormatGeoJSON ExportFormat = "geojson"
+	ExportFormatKML     ExportFormat = "kml"
+	ExportFormatKMZ     ExportFormat = "kmz"
+	ExportFormatTCX     ExportFormat = "tcx"
+	ExportFormatCSV     ExportFormat = "csv"
+	ExportFormatGPX     ExportFormat = "gpx"
+)
+
+const (
+	// KMLNamespace is the OGC KML 2.2 namespace
+	KMLNamespace = "http://www.opengis.net/kml/2.2"
+	// TCXNamespace is the Garmin Training Center Database v2 namespace
+	TCXNamespace = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+)
+
+// ParseExportFormat validates a requested export format name
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch format := ExportFormat(name); format {
+	case ExportFormatGeoJSON, ExportFormatKML, ExportFormatKMZ, ExportFormatTCX, ExportFormatCSV, ExportFormatGPX:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q: expected geojson, kml, kmz, tcx, csv or gpx", name)
+}
+
+// ContentType returns the MIME type of the exported document
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatGeoJSON:
+		return "application/geo+json"
+	case ExportFormatKML:
+		return TrackFormatKML.ContentType()
+	case ExportFormatKMZ:
+		return TrackFormatKMZ.ContentType()
+	case ExportFormatTCX:
+		return TrackFormatTCX.ContentType()
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return TrackFormatGPX.ContentType()
+}
+
+// RouteExport is the stored data of a route that exports are built from
+type RouteExport struct {
+	ID           string
+	Name         string
+	Description  string
+	Difficulty   string
+	CreatorName  string
+	RouteLength  *float64 // in km
+	TotalAscent  *float64 // in meters
+	TotalDescent *float64 // in meters
+	StartTime    *time.Time
+	EndTime      *time.Time
+	Lines        [][][]float64 // GeoJSON [longitude, latitude(, elevation)] coordinates per line
+	PointTimes   []*time.Time  // recorded time of every coordinate of Lines, flattened, nil if unknown
+	Waypoints    []Waypoint
+}
+
+// hasElevation reports whether the route has elevation data. Geometries are stored in 3D,
+// so routes without elevation data have Z = 0 everywhere.
+func (r *RouteExport) hasElevation() bool {
+	for _, line := range r.Lines {
+		for _, coord := range line {
+			if len(coord) > 2 && coord[2] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exportLines returns the route lines, leaving out the elevation of routes without elevation data
+func (r *RouteExport) exportLines() [][][]float64 {
+	if r.hasElevation() {
+		return r.Lines
+	}
+	lines := make([][][]float64, 0, len(r.Lines))
+	for _, line := range r.Lines {
+		flat := make([][]float64, 0, len(line))
+		for _, coord := range line {
+			flat = append(flat, coord[:2])
+		}
+		lines = append(lines, flat)
+	}
+	return lines
+}
+
+// alignedPointTimes returns the recorded point times if they line up with the geometry, nil otherwise
+func (r *RouteExport) alignedPointTimes() []*time.Time {
+	count := 0
+	for _, line := range r.Lines {
+		count += len(line)
+	}
+	if len(r.PointTimes) != count {
+		return nil
+	}
+	return r.PointTimes
+}
+
+// exportPoints converts the export lines into points with their recorded times
+func (r *RouteExport) exportPoints() [][]Waypoint {
+	times := r.alignedPointTimes()
+	lines := make([][]Waypoint, 0, len(r.Lines))
+	index := 0
+	for _, line := range r.exportLines() {
+		points := make([]Waypoint, 0, len(line))
+		for _, coord := range line {
+			wp := coordinateWaypoint(coord)
+			if times != nil && times[index] != nil {
+				t := times[index].UTC().Format(time.RFC3339)
+				wp.Time = &t
+			}
+			points = append(points, wp)
+			index++
+		}
+		lines = append(lines, points)
+	}
+	return lines
+}
+
+// ParseExportGeometry reads the lines of a LineString or MultiLineString GeoJSON geometry
+func ParseExportGeometry(geoJSON string) ([][][]float64, error) {
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal([]byte(geoJSON), &geometry); err != nil {
+		return nil, fmt.Errorf("failed to parse route geometry: %w", err)
+	}
+
+	switch geometry.Type {
+	case "LineString":
+		var line [][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &line); err != nil {
+			return nil, fmt.Errorf("failed to parse route geometry: %w", err)
+		}
+		return [][][]float64{line}, nil
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &lines); err != nil {
+			return nil, fmt.Errorf("failed to parse route geometry: %w", err)
+		}
+		return lines, nil
+	}
+	return nil, fmt.Errorf("unsupported route geometry type %q", geometry.Type)
+}
+
+// coordinateWaypoint converts a GeoJSON coordinate into a point
+func coordinateWaypoint(coord []float64) Waypoint {
+	wp := Waypoint{Lon: coord[0], Lat: coord[1]}
+	if len(coord) > 2 {
+		ele := coord[2]
+		wp.Ele = &ele
+	}
+	return wp
+}
+
+// formatFloat formats a coordinate or measurement without trailing zeros
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// WriteRouteExport writes the route in the requested format
+func WriteRouteExport(w io.Writer, route *RouteExport, format ExportFormat) error {
+	switch format {
+	case ExportFormatGeoJSON:
+		return writeGeoJSONExport(w, route)
+	case ExportFormatKML:
+		return writeKMLExport(w, route)
+	case ExportFormatKMZ:
+		archive := zip.NewWriter(w)
+		document, err := archive.Create("doc.kml")
+		if err != nil {
+			return err
+		}
+		if err := writeKMLExport(document, route); err != nil {
+			return err
+		}
+		return archive.Close()
+	case ExportFormatTCX:
+		return writeTCXExport(w, route)
+	case ExportFormatCSV:
+		return writeCSVExport(w, route)
+	case ExportFormatGPX:
+		return EncodeGPX(w, route.toGPX())
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// toGPX builds a GPX document with one track segment per line
+func (r *RouteExport) toGPX() *GPX {
+	track := Track{Name: r.Name}
+	for _, points := range r.exportPoints() {
+		track.Segments = append(track.Segments, Segment{Points: points})
+	}
+	return &GPX{Waypoints: r.Waypoints, Tracks: []Track{track}}
+}
+
+// properties returns the route metadata as GeoJSON feature properties
+func (r *RouteExport) properties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"id":         r.ID,
+		"name":       r.Name,
+		"type":       "route",
+		"difficulty": r.Difficulty,
+	}
+	if r.Description != "" {
+		properties["description"] = r.Description
+	}
+	if r.CreatorName != "" {
+		properties["creator_name"] = r.CreatorName
+	}
+	if r.RouteLength != nil {
+		properties["route_length_km"] = *r.RouteLength
+	}
+	if r.TotalAscent != nil {
+		properties["total_ascent"] = *r.TotalAscent
+	}
+	if r.TotalDescent != nil {
+		properties["total_descent"] = *r.TotalDescent
+	}
+	if r.StartTime != nil {
+		properties["start_time"] = r.StartTime.Format(time.RFC3339)
+	}
+	if r.EndTime != nil {
+		properties["end_time"] = r.EndTime.Format(time.RFC3339)
+	}
+	return properties
+}
+
+// writeGeoJSONExport writes a FeatureCollection with the route line and its waypoints
+func writeGeoJSONExport(w io.Writer, route *RouteExport) error {
+	lines := route.exportLines()
+	geometry := Geometry{Type: "MultiLineString", Coordinates: lines}
+	if len(lines) == 1 {
+		geometry = Geometry{Type: "LineString", Coordinates: lines[0]}
+	}
+
+	features := []Feature{{Type: "Feature", Properties: route.properties(), Geometry: geometry}}
+	for i, wp := range route.Waypoints {
+		properties := map[string]interface{}{
+			"type":           "waypoint",
+			"waypoint_index": i,
+		}
+		if wp.Name != nil {
+			properties["name"] = *wp.Name
+		}
+		if wp.Time != nil {
+			properties["time"] = *wp.Time
+		}
+		if wp.Description != nil {
+			properties["description"] = *wp.Description
+		}
+		if wp.Symbol != nil {
+			properties["symbol"] = *wp.Symbol
+		}
+		features = append(features, Feature{
+			Type:       "Feature",
+			Properties: properties,
+			Geometry:   Geometry{Type: "Point", Coordinates: pointCoordinates(wp)},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(GeoJSON{Type: "FeatureCollection", Features: features})
+}
+
+// kmlCoordinates formats points as the whitespace separated "lon,lat[,alt]" tuples of a <coordinates> element
+func kmlCoordinates(line [][]float64) string {
+	buf := make([]byte, 0, len(line)*32)
+	for i, coord := range line {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		for j, value := range coord {
+			if j > 0 {
+				buf = append(buf, ',')
+			}
+			buf = strconv.AppendFloat(buf, value, 'f', -1, 64)
+		}
+	}
+	return string(buf)
+}
+
+// kmlPlacemark is a KML <Placemark> with either a point or a (multi) line geometry
+type kmlPlacemark struct {
+	Name          string            `xml:"name,omitempty"`
+	Description   string            `xml:"description,omitempty"`
+	Point         *kmlGeometry      `xml:"Point,omitempty"`
+	LineString    *kmlGeometry      `xml:"LineString,omitempty"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry,omitempty"`
+}
+
+// kmlGeometry is a KML <Point> or <LineString>
+type kmlGeometry struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlMultiGeometry groups the lines of a route with several segments
+type kmlMultiGeometry struct {
+	Lines []kmlGeometry `xml:"LineString"`
+}
+
+// writeKMLExport writes a KML document with the route as a line placemark followed by waypoint placemarks
+func writeKMLExport(w io.Writer, route *RouteExport) error {
+	var document struct {
+		XMLName     xml.Name       `xml:"kml"`
+		Namespace   string         `xml:"xmlns,attr"`
+		Name        string         `xml:"Document>name"`
+		Description string         `xml:"Document>description,omitempty"`
+		Placemarks  []kmlPlacemark `xml:"Document>Placemark"`
+	}
+	document.Namespace = KMLNamespace
+	document.Name = route.Name
+	document.Description = route.Description
+
+	placemark := kmlPlacemark{Name: route.Name, Description: route.Description}
+	lines := make([]kmlGeometry, 0, len(route.Lines))
+	for _, line := range route.exportLines() {
+		lines = append(lines, kmlGeometry{Coordinates: kmlCoordinates(line)})
+	}
+	if len(lines) == 1 {
+		placemark.LineString = &lines[0]
+	} else {
+		placemark.MultiGeometry = &kmlMultiGeometry{Lines: lines}
+	}
+	document.Placemarks = append(document.Placemarks, placemark)
+
+	for _, wp := range route.Waypoints {
+		point := kmlPlacemark{Point: &kmlGeometry{Coordinates: kmlCoordinates([][]float64{pointCoordinates(wp)})}}
+		if wp.Name != nil {
+			point.Name = *wp.Name
+		}
+		if wp.Description != nil {
+			point.Description = *wp.Description
+		}
+		document.Placemarks = append(document.Placemarks, point)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to encode KML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// tcxTrackpointExport is a <Trackpoint> of an exported TCX course
+type tcxTrackpointExport struct {
+	Time      string   `xml:"Time,omitempty"`
+	Latitude  float64  `xml:"Position>LatitudeDegrees"`
+	Longitude float64  `xml:"Position>LongitudeDegrees"`
+	Altitude  *float64 `xml:"AltitudeMeters,omitempty"`
+	Distance  float64  `xml:"DistanceMeters"`
+}
+
+// writeTCXExport writes the route as a TCX course. Trackpoints carry their recorded times, which are
+// left out for points without one.
+func writeTCXExport(w io.Writer, route *RouteExport) error {
+	var points []tcxTrackpointExport
+	distance := 0.0
+	for _, line := range route.exportPoints() {
+		for i, wp := range line {
+			if i > 0 {
+				prev := line[i-1]
+				distance += HaversineDistance(prev.Lat, prev.Lon, wp.Lat, wp.Lon)
+			}
+			point := tcxTrackpointExport{Latitude: wp.Lat, Longitude: wp.Lon, Altitude: wp.Ele, Distance: distance}
+			if wp.Time != nil {
+				point.Time = *wp.Time
+			}
+			points = append(points, point)
+		}
+	}
+
+	totalSeconds := 0.0
+	if route.StartTime != nil && route.EndTime != nil && route.EndTime.After(*route.StartTime) {
+		totalSeconds = route.EndTime.Sub(*route.StartTime).Seconds()
+	}
+
+	// TCX course names are limited to 15 characters
+	name := []rune(route.Name)
+	if len(name) > 15 {
+		name = name[:15]
+	}
+
+	var document struct {
+		XMLName     xml.Name              `xml:"TrainingCenterDatabase"`
+		Namespace   string                `xml:"xmlns,attr"`
+		Name        string                `xml:"Courses>Course>Name"`
+		TotalTime   string                `xml:"Courses>Course>Lap>TotalTimeSeconds"`
+		Distance    string                `xml:"Courses>Course>Lap>DistanceMeters"`
+		Intensity   string                `xml:"Courses>Course>Lap>Intensity"`
+		Trackpoints []tcxTrackpointExport `xml:"Courses>Course>Track>Trackpoint"`
+	}
+	document.Namespace = TCXNamespace
+	document.Name = strings.TrimSpace(string(name))
+	document.TotalTime = formatFloat(totalSeconds)
+	document.Distance = formatFloat(distance)
+	document.Intensity = "Active"
+	document.Trackpoints = points
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to encode TCX: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeCSVExport writes one row per route point with the cumulative distance
+func writeCSVExport(w io.Writer, route *RouteExport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"segment", "latitude", "longitude", "elevation_m", "distance_km"}); err != nil {
+		return err
+	}
+
+	distance := 0.0
+	for s, line := range route.exportLines() {
+		for i, coord := range line {
+			if i > 0 {
+				prev := line[i-1]
+				distance += HaversineDistance(prev[1], prev[0], coord[1], coord[0])
+			}
+			elevation := ""
+			if len(coord) > 2 {
+				elevation = formatFloat(coord[2])
+			}
+			record := []string{
+				strconv.Itoa(s),
+				formatFloat(coord[1]),
+				formatFloat(coord[0]),
+				elevation,
+				strconv.FormatFloat(distance/1000, 'f', 3, 64),
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// DefaultProfileResolution is the number of samples returned when no resolution is requested
+	DefaultProfileResolution = 500
+	// MinProfileResolution and MaxProfileResolution bound the requested number of samples
+	MinProfileResolution = 2
+	MaxProfileResolution = 5000
+)
+
+// RouteProfile holds distance-indexed series for drawing elevation and speed charts.
+// All series have one value per sample; grade and speed describe the interval ending at the sample
+// and are null for the first sample, across a gap between segments or when they cannot be calculated.
+type RouteProfile struct {
+	TotalDistanceKm float64      `json:"total_distance_km"`
+	PointCount      int          `json:"point_count"` // points in the stored route geometry
+	Resolution      int          `json:"resolution"`  // number of samples in each series
+	HasElevation    bool         `json:"has_elevation"`
+	HasTime         bool         `json:"has_time"`
+	DistanceKm      []float64    `json:"distance_km"`
+	Elevation       []float64    `json:"elevation_m,omitempty"`
+	Grade           []*float64   `json:"grade_percent,omitempty"`
+	Speed           []*float64   `json:"speed_kmh,omitempty"`
+	Time            []*time.Time `json:"time,omitempty"`
+}
+
+// profilePoint is a point of the route with its cumulative distance along the geometry
+type profilePoint struct {
+	Distance float64 // in meters
+	Ele      float64
+	Time     *time.Time
+	Segment  int // index of the geometry line the point belongs to
+}
+
+// BuildRouteProfile computes the profile of a route from its geometry lines and optional per-point timestamps
+// (aligned with the flattened coordinates, as stored on upload). Routes with more points than resolution are
+// resampled at evenly spaced distances. The gap between two segments does not count towards the distance.
+func BuildRouteProfile(lines [][][]float64, times []*time.Time, resolution int) (*RouteProfile, error) {
+	var points []profilePoint
+	hasElevation := false
+	distance := 0.0
+	for segment, line := range lines {
+		for i, coord := range line {
+			if len(coord) < 2 {
+				return nil, fmt.Errorf("invalid coordinate in route geometry")
+			}
+			if i > 0 {
+				prev := line[i-1]
+				distance += HaversineDistance(prev[1], prev[0], coord[1], coord[0])
+			}
+			point := profilePoint{Distance: distance, Segment: segment}
+			// Geometries are stored in 3D, so routes without elevation data have Z = 0 everywhere
+			if len(coord) > 2 {
+				point.Ele = coord[2]
+				hasElevation = hasElevation || coord[2] != 0
+			}
+			points = append(points, point)
+		}
+	}
+	if len(points) < MinSegmentPoints {
+		return nil, fmt.Errorf("route geometry has fewer than %d points", MinSegmentPoints)
+	}
+
+	// Timestamps only make sense when they line up with the geometry
+	hasTime := false
+	if len(times) == len(points) {
+		for i, t := range times {
+			points[i].Time = t
+			hasTime = hasTime || t != nil
+		}
+	}
+
+	if resolution < MinProfileResolution {
+		resolution = MinProfileResolution
+	}
+	if resolution > MaxProfileResolution {
+		resolution = MaxProfileResolution
+	}
+	samples := points
+	if len(points) > resolution && distance > 0 {
+		samples = resampleProfile(points, resolution)
+	}
+
+	profile := &RouteProfile{
+		TotalDistanceKm: RoundTo(distance/1000, 3),
+		PointCount:      len(points),
+		Resolution:      len(samples),
+		HasElevation:    hasElevation,
+		HasTime:         hasTime,
+		DistanceKm:      make([]float64, len(samples)),
+	}
+	if hasElevation {
+		profile.Elevation = make([]float64, len(samples))
+		profile.Grade = make([]*float64, len(samples))
+	}
+	if hasTime {
+		profile.Speed = make([]*float64, len(samples))
+		profile.Time = make([]*time.Time, len(samples))
+	}
+
+	for i, sample := range samples {
+		profile.DistanceKm[i] = RoundTo(sample.Distance/1000, 3)
+		if hasElevation {
+			profile.Elevation[i] = RoundTo(sample.Ele, 1)
+		}
+		if hasTime {
+			profile.Time[i] = sample.Time
+		}
+		if i == 0 {
+			continue
+		}
+
+		prev := samples[i-1]
+		if prev.Segment != sample.Segment {
+			continue
+		}
+		delta := sample.Distance - prev.Distance
+		if hasElevation && delta > 0 {
+			grade := RoundTo((sample.Ele-prev.Ele)/delta*100, 1)
+			profile.Grade[i] = &grade
+		}
+		if hasTime && sample.Time != nil && prev.Time != nil {
+			if seconds := sample.Time.Sub(*prev.Time).Seconds(); seconds > 0 {
+				speed := RoundTo(delta/seconds*3.6, 2)
+				profile.Speed[i] = &speed
+			}
+		}
+	}
+
+	return profile, nil
+}
+
+// resampleProfile interpolates count points at evenly spaced distances along the route
+func resampleProfile(points []profilePoint, count int) []profilePoint {
+	total := points[len(points)-1].Distance
+	samples := make([]profilePoint, 0, count)
+
+	j := 0
+	for k := 0; k < count; k++ {
+		target := total * float64(k) / float64(count-1)
+		for j < len(points)-2 && points[j+1].Distance < target {
+			j++
+		}
+
+		a, b := points[j], points[j+1]
+		ratio := 0.0
+		if b.Distance > a.Distance {
+			ratio = math.Min(math.Max((target-a.Distance)/(b.Distance-a.Distance), 0), 1)
+		}
+
+		sample := profilePoint{Distance: target, Ele: a.Ele + (b.Ele-a.Ele)*ratio, Segment: a.Segment}
+		if ratio == 1 {
+			sample.Segment = b.Segment
+		}
+		if a.Time != nil && b.Time != nil {
+			t := a.Time.Add(time.Duration(float64(b.Time.Sub(*a.Time)) * ratio)).Round(time.Second)
+			sample.Time = &t
+		} else if ratio == 0 {
+			sample.Time = a.Time
+		} else if ratio == 1 {
+			sample.Time = b.Time
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// RoundTo rounds value to the given number of decimals
+func RoundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MetersPerMile is the length of a statute mile
+	MetersPerMile = 1609.344
+	// MinSplitDistance is the shortest split distance accepted, in meters
+	MinSplitDistance = 100.0
+	// DefaultSplitDistance is the split distance used when none is requested
+	DefaultSplitDistance = "1km"
+)
+
+// RouteSplit summarizes one split or lap of a route. Timing fields require timestamps, elevation
+// fields elevation data and AvgHeartRate heart rate readings; they are omitted otherwise.
+type RouteSplit struct {
+	Index            int      `json:"index"`
+	StartDistanceKm  float64  `json:"start_distance_km"`
+	DistanceKm       float64  `json:"distance_km"`
+	TimeSeconds      *int     `json:"time_seconds,omitempty"`        // moving time in the split, excluding stops and gaps between segments
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km,omitempty"` // average moving pace over the split
+	ElevationGain    *float64 `json:"elevation_gain,omitempty"`      // smoothed, in meters
+	ElevationLoss    *float64 `json:"elevation_loss,omitempty"`      // smoothed, in meters
+	AvgHeartRate     *float64 `json:"avg_heart_rate,omitempty"`      // in bpm
+}
+
+// RouteSplits holds the splits of a route at a fixed distance and its laps (one per track segment)
+type RouteSplits struct {
+	SplitDistanceKm float64      `json:"split_distance_km"`
+	TotalDistanceKm float64      `json:"total_distance_km"`
+	HasTime         bool         `json:"has_time"`
+	HasElevation    bool         `json:"has_elevation"`
+	HasHeartRate    bool         `json:"has_heart_rate"`
+	Splits          []RouteSplit `json:"splits"`
+	Laps            []RouteSplit `json:"laps"`
+}
+
+// ParseSplitDistance converts a split distance such as "1km", "1mi", "5km" or "400m" into meters
+func ParseSplitDistance(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	unit := 1000.0
+	number := value
+	switch {
+	case strings.HasSuffix(value, "km"):
+		number = strings.TrimSuffix(value, "km")
+	case strings.HasSuffix(value, "mi"):
+		unit, number = MetersPerMile, strings.TrimSuffix(value, "mi")
+	case strings.HasSuffix(value, "m"):
+		unit, number = 1, strings.TrimSuffix(value, "m")
+	}
+
+	amount, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, fmt.Errorf("invalid split distance %q: use a distance such as 1km, 1mi or 5km", value)
+	}
+	if meters := amount * unit; meters >= MinSplitDistance {
+		return meters, nil
+	}
+	return 0, fmt.Errorf("split distance must be at least %.0f m", MinSplitDistance)
+}
+
+// splitSeries holds cumulative values for every point of a route so any distance range can be summarized
+// by interpolating at its ends
+type splitSeries struct {
+	distance   []float64 // along the geometry, in meters
+	moving     []float64 // moving time within segments, in seconds, with stops detected as for moving_time_seconds
+	ascent     []float64
+	descent    []float64
+	heartRates []*float64
+}
+
+// CalculateRouteSplits computes splits every splitMeters along the route and one lap per geometry line,
+// using the per-point timestamps and heart rates stored on upload (aligned with the flattened coordinates).
+func CalculateRouteSplits(lines [][][]float64, times []*time.Time, heartRates []*float64, splitMeters float64) (*RouteSplits, error) {
+	if splitMeters < MinSplitDistance {
+		return nil, fmt.Errorf("split distance must be at least %.0f m", MinSplitDistance)
+	}
+
+	count := 0
+	for _, line := range lines {
+		count += len(line)
+	}
+	if count < MinSegmentPoints {
+		return nil, fmt.Errorf("route geometry has fewer than %d points", MinSegmentPoints)
+	}
+	// Point data only makes sense when it lines up with the geometry
+	if len(times) != count {
+		times = nil
+	}
+	if len(heartRates) != count {
+		heartRates = nil
+	}
+
+	result := &RouteSplits{SplitDistanceKm: RoundTo(splitMeters/1000, 3)}
+	series := &splitSeries{heartRates: heartRates}
+	lapBounds := make([][2]int, 0, len(lines))
+
+	distance, moving, ascent, descent := 0.0, 0.0, 0.0, 0.0
+	for _, line := range lines {
+		start := len(series.distance)
+		movingTime := lineMovingTime(line, times, start)
+		elevations := make([]float64, len(line))
+		for i, coord := range line {
+			if len(coord) < 2 {
+				return nil, fmt.Errorf("invalid coordinate in route geometry")
+			}
+			if len(coord) > 2 {
+				elevations[i] = coord[2]
+				// Geometries are stored in 3D, so routes without elevation data have Z = 0 everywhere
+				result.HasElevation = result.HasElevation || coord[2] != 0
+			}
+
+			index := start + i
+			if i > 0 {
+				prev := line[i-1]
+				distance += HaversineDistance(prev[1], prev[0], coord[1], coord[0])
+			}
+			moving += movingTime[i]
+			if times != nil && times[index] != nil {
+				result.HasTime = true
+			}
+			if heartRates != nil && heartRates[index] != nil {
+				result.HasHeartRate = true
+			}
+			series.distance = append(series.distance, distance)
+			series.moving = append(series.moving, moving)
+		}
+
+		// Accumulate smoothed elevation change within the segment, the gap to the next one is not climbed
+		smoothed := SmoothElevations(elevations, ElevationSmoothingWindow)
+		reference := smoothed[0]
+		for _, ele := range smoothed {
+			delta := ele - reference
+			if delta >= ElevationHysteresisMeters {
+				ascent += delta
+				reference = ele
+			} else if -delta >= ElevationHysteresisMeters {
+				descent += -delta
+				reference = ele
+			}
+			series.ascent = append(series.ascent, ascent)
+			series.descent = append(series.descent, descent)
+		}
+
+		lapBounds = append(lapBounds, [2]int{start, len(series.distance) - 1})
+	}
+
+	result.TotalDistanceKm = RoundTo(distance/1000, 3)
+
+	// The tolerance keeps rounding errors from producing an empty last split
+	splitCount := int(math.Ceil(distance/splitMeters - 1e-9))
+	result.Splits = make([]RouteSplit, 0, splitCount)
+	for i := 0; i < splitCount; i++ {
+		start := float64(i) * splitMeters
+		end := math.Min(start+splitMeters, distance)
+		first := sort.SearchFloat64s(series.distance, start)
+		last := sort.Search(len(series.distance), func(j int) bool { return series.distance[j] > end }) - 1
+		result.Splits = append(result.Splits, series.summarize(result, i, start, end, first, last))
+	}
+
+	result.Laps = make([]RouteSplit, 0, len(lapBounds))
+	for i, bounds := range lapBounds {
+		start, end := series.distance[bounds[0]], series.distance[bounds[1]]
+		result.Laps = append(result.Laps, series.summarize(result, i, start, end, bounds[0], bounds[1]))
+	}
+
+	return result, nil
+}
+
+// lineMovingTime returns for every point of a line the moving time of the interval ending there, using the
+// stop detection of CalculateMotionStats so split paces match the moving average speed. start is the index
+// of the first point of the line in times; points without a timestamp get no time.
+func lineMovingTime(line [][]float64, times []*time.Time, start int) []float64 {
+	movingTime := make([]float64, len(line))
+	if times == nil {
+		return movingTime
+	}
+
+	var timed []TimedPoint
+	var indexes []int
+	for i, coord := range line {
+		if t := times[start+i]; t != nil && len(coord) >= 2 {
+			timed = append(timed, TimedPoint{Lat: coord[1], Lon: coord[0], Time: *t})
+			indexes = append(indexes, i)
+		}
+	}
+	for k := 1; k < len(timed); k++ {
+		dt := timed[k].Time.Sub(timed[k-1].Time).Seconds()
+		if dt > 0 && intervalSpeed(timed, k) >= MinMovingSpeedMPS {
+			movingTime[indexes[k]] = dt
+		}
+	}
+	return movingTime
+}
+
+// summarize builds the split covering the distance range [start, end], whose points are first..last
+func (s *splitSeries) summarize(result *RouteSplits, index int, start, end float64, first, last int) RouteSplit {
+	split := RouteSplit{
+		Index:           index,
+		StartDistanceKm: RoundTo(start/1000, 3),
+		DistanceKm:      RoundTo((end-start)/1000, 3),
+	}
+
+	if result.HasTime {
+		seconds := s.interpolate(s.moving, end) - s.interpolate(s.moving, start)
+		timeSeconds := int(math.Round(seconds))
+		split.TimeSeconds = &timeSeconds
+		if end > start && seconds > 0 {
+			pace := RoundTo(seconds/((end-start)/1000), 1)
+			split.PaceSecondsPerKm = &pace
+		}
+	}
+
+	if result.HasElevation {
+		gain := RoundTo(s.interpolate(s.ascent, end)-s.interpolate(s.ascent, start), 1)
+		loss := RoundTo(s.interpolate(s.descent, end)-s.interpolate(s.descent, start), 1)
+		split.ElevationGain = &gain
+		split.ElevationLoss = &loss
+	}
+
+	if result.HasHeartRate {
+		sum, n := 0.0, 0
+		for i := first; i <= last; i++ {
+			if hr := s.heartRates[i]; hr != nil {
+				sum += *hr
+				n++
+			}
+		}
+		if n > 0 {
+			avg := RoundTo(sum/float64(n), 1)
+			split.AvgHeartRate = &avg
+		}
+	}
+
+	return split
+}
+
+// interpolate returns the cumulative value at the given distance along the route
+func (s *splitSeries) interpolate(values []float64, distance float64) float64 {
+	j := sort.SearchFloat64s(s.distance, distance)
+	if j == 0 {
+		return values[0]
+	}
+	if j >= len(s.distance) {
+		return values[len(values)-1]
+	}
+
+	a, b := s.distance[j-1], s.distance[j]
+	if b <= a {
+		return values[j]
+	}
+	ratio := (distance - a) / (b - a)
+	return values[j-1] + (values[j]-values[j-1])*ratio
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotTCX is returned when the document root is not a <TrainingCenterDatabase> element
+var ErrNotTCX = errors.New("document is not a TCX file")
+
+// tcxTrackpoint is a single <Trackpoint> of a TCX activity or course
+type tcxTrackpoint struct {
+	Time     string `xml:"Time"`
+	Position *struct {
+		Lat float64 `xml:"LatitudeDegrees"`
+		Lon float64 `xml:"LongitudeDegrees"`
+	} `xml:"Position"`
+	Altitude   *float64    `xml:"AltitudeMeters"`
+	HeartRate  *float64    `xml:"HeartRateBpm>Value"`
+	Cadence    *float64    `xml:"Cadence"`
+	Extensions *SensorData `xml:"Extensions"` // ActivityExtension TPX: Speed, Watts, RunCadence
+}
+
+// toWaypoint converts the trackpoint into a GPX track point
+func (tp *tcxTrackpoint) toWaypoint() (Waypoint, bool) {
+	if tp.Position == nil {
+		// Trackpoints without a position (e.g. indoor or before GPS fix) cannot be placed on a track
+		return Waypoint{}, false
+	}
+
+	wp := Waypoint{Lat: tp.Position.Lat, Lon: tp.Position.Lon, Ele: tp.Altitude}
+	if tp.Time != "" {
+		t := tp.Time
+		wp.Time = &t
+	}
+
+	sensors := tp.Extensions
+	if sensors == nil {
+		sensors = &SensorData{}
+	}
+	if tp.HeartRate != nil {
+		sensors.HeartRate = tp.HeartRate
+	}
+	if tp.Cadence != nil && sensors.Cadence == nil {
+		sensors.Cadence = tp.Cadence
+	}
+	if !sensors.IsEmpty() {
+		wp.Sensors = sensors
+	}
+
+	return wp, true
+}
+
+// DecodeTCX converts the activities and courses of a Garmin TCX document into the internal GPX representation.
+// Every <Activity> or <Course> becomes a track and every <Track> inside it a track segment.
+func DecodeTCX(r io.Reader) (*GPX, error) {
+	decoder := xml.NewDecoder(r)
+
+	gpx := &GPX{}
+	var stack []string
+	var current *Track
+	var segment *Segment
+	points := 0
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TCX: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			} else if name != "TrainingCenterDatabase" {
+				return nil, ErrNotTCX
+			}
+
+			switch {
+			case name == "Activity" || name == "Course":
+				gpx.Tracks = append(gpx.Tracks, Track{Name: "TCX " + name})
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "Sport" && attr.Value != "" {
+						gpx.Tracks[len(gpx.Tracks)-1].Name = attr.Value + " activity"
+					}
+				}
+				current = &gpx.Tracks[len(gpx.Tracks)-1]
+			case name == "Name" && parent == "Course" && current != nil:
+				var courseName string
+				if err := decoder.DecodeElement(&courseName, &t); err != nil {
+					return nil, fmt.Errorf("failed to decode course name: %w", err)
+				}
+				current.Name = courseName
+				continue
+			case name == "Track" && current != nil:
+				current.Segments = append(current.Segments, Segment{})
+				segment = &current.Segments[len(current.Segments)-1]
+			case name == "Trackpoint" && parent == "Track" && segment != nil:
+				var tp tcxTrackpoint
+				if err := decoder.DecodeElement(&tp, &t); err != nil {
+					return nil, fmt.Errorf("failed to decode <Trackpoint>: %w", err)
+				}
+				if wp, ok := tp.toWaypoint(); ok {
+					points++
+					if points > MaxStreamPoints {
+						return nil, fmt.Errorf("TCX file exceeds the maximum of %d points", MaxStreamPoints)
+					}
+					segment.Points = append(segment.Points, wp)
+				}
+				continue
+			}
+
+			stack = append(stack, name)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			if t.Name.Local == "Track" {
+				segment = nil
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("failed to parse TCX: unexpected end of document inside <%s>", stack[len(stack)-1])
+	}
+	if points == 0 {
+		return nil, fmt.Errorf("no positioned trackpoints found in TCX file")
+	}
+
+	return dropEmptySegments(gpx), nil
+}
+
+// dropEmptySegments removes segments and tracks without points from a decoded document
+func dropEmptySegments(gpx *GPX) *GPX {
+	tracks := gpx.Tracks[:0]
+	for _, track := range gpx.Tracks {
+		segments := track.Segments[:0]
+		for _, segment := range track.Segments {
+			if len(segment.Points) > 0 {
+				segments = append(segments, segment)
+			}
+		}
+		track.Segments = segments
+		if len(track.Segments) > 0 {
+			tracks = append(tracks, track)
+		}
+	}
+	gpx.Tracks = tracks
+	return gpx
+}
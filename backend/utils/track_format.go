@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TrackFormat identifies the file format of an uploaded activity or route
+type TrackFormat string
+
+const (
+	TrackFormatGPX TrackFormat = "gpx"
+	TrackFormatFIT TrackFormat = "fit"
+	TrackFormatTCX TrackFormat = "tcx"
+	TrackFormatKML TrackFormat = "kml"
+	TrackFormatKMZ TrackFormat = "kmz"
+)
+
+// trackFormatSniffSize is the number of leading bytes inspected to detect the format
+const trackFormatSniffSize = 4096
+
+// ErrUnknownTrackFormat is returned when the content does not match any supported format
+var ErrUnknownTrackFormat = errors.New("unsupported file format: expected GPX, FIT, TCX, KML or KMZ")
+
+// ContentType returns the MIME type used when storing files of this format
+func (f TrackFormat) ContentType() string {
+	switch f {
+	case TrackFormatFIT:
+		return "application/vnd.ant.fit"
+	case TrackFormatTCX:
+		return "application/vnd.garmin.tcx+xml"
+	case TrackFormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	case TrackFormatKMZ:
+		return "application/vnd.google-earth.kmz"
+	}
+	return "application/gpx+xml"
+}
+
+// DetectTrackFormat inspects the beginning of the content to determine its format, ignoring the file name.
+// Binary formats are recognized by their signature, XML formats by the name of the root element.
+func DetectTrackFormat(r io.ReaderAt) (TrackFormat, error) {
+	header := make([]byte, trackFormatSniffSize)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read file header: %w", err)
+	}
+	header = header[:n]
+
+	if len(header) >= 12 && (header[0] == 12 || header[0] == 14) && string(header[8:12]) == ".FIT" {
+		return TrackFormatFIT, nil
+	}
+	if bytes.HasPrefix(header, []byte("PK\x03\x04")) {
+		return TrackFormatKMZ, nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))))
+	// Only the root element name is needed, so any declared encoding is read as-is
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", ErrUnknownTrackFormat
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "gpx":
+				return TrackFormatGPX, nil
+			case "TrainingCenterDatabase":
+				return TrackFormatTCX, nil
+			case "kml":
+				return TrackFormatKML, nil
+			}
+			return "", ErrUnknownTrackFormat
+		}
+	}
+}
+
+// DecodeTrackFile converts an activity or route file of the given format into the internal GPX representation
+func DecodeTrackFile(format TrackFormat, r io.ReaderAt, size int64) (*GPX, error) {
+	content := io.NewSectionReader(r, 0, size)
+	switch format {
+	case TrackFormatGPX:
+		var gpx GPX
+		if err := xml.NewDecoder(content).Decode(&gpx); err != nil {
+			return nil, fmt.Errorf("failed to parse GPX: %w", err)
+		}
+		return &gpx, nil
+	case TrackFormatFIT:
+		return DecodeFIT(content)
+	case TrackFormatTCX:
+		return DecodeTCX(content)
+	case TrackFormatKML:
+		return DecodeKML(content)
+	case TrackFormatKMZ:
+		return DecodeKMZ(r, size)
+	}
+	return nil, ErrUnknownTrackFormat
+}
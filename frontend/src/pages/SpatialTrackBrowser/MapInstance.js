@@ -278,7 +278,7 @@ export const getMarkerCount = () => {
 };
 
 // Add or update simplified paths using SimplifiedPathManager
-// pathData should be an array of { routeId, coordinates, routeName }
+// pathData should be an array of { routeId, coordinates, geometry, routeName }
 export const updateSimplifiedPaths = (pathData) => {
   if (!mapInstance) {
     console.warn('No map instance available for updating paths');
@@ -14,8 +14,8 @@ class SimplifiedPathManager {
       return;
     }
 
-    // pathData should be an array of { routeId, coordinates, routeName }
-    // where coordinates is an array of [lng, lat] pairs
+    // pathData should be an array of { routeId, coordinates, geometry, routeName }
+    // where coordinates is an array of [lng, lat] pairs and geometry the LineString or MultiLineString to draw
     
     // Keep track of current route IDs
     const currentRouteIds = new Set(pathData.map(pd => pd.routeId));
@@ -47,7 +47,7 @@ class SimplifiedPathManager {
 
   // Add a single simplified path
   addPath(pathInfo, mapInstance) {
-    const { routeId, coordinates, routeName } = pathInfo;
+    const { routeId, coordinates, geometry, routeName } = pathInfo;
     
     if (this.paths.has(routeId)) {
       console.log(`Path for route ${routeId} already exists, skipping`);
@@ -73,7 +73,7 @@ class SimplifiedPathManager {
               routeId: routeId,
               routeName: routeName
             },
-            geometry: {
+            geometry: geometry || {
               type: 'LineString',
               coordinates: coordinates
             }
@@ -17,7 +17,7 @@ export const parseCenterPoint = (centerPointString) => {
   }
 };
 
-// Parse simplified_path from GeoJSON string to coordinates array
+// Parse simplified_path from GeoJSON string to a LineString or MultiLineString geometry
 export const parseSimplifiedPath = (simplifiedPathString) => {
   try {
     if (!simplifiedPathString) return null;
@@ -25,8 +25,13 @@ export const parseSimplifiedPath = (simplifiedPathString) => {
     const geoJson = JSON.parse(simplifiedPathString);
     
     if (geoJson.type === 'LineString' && geoJson.coordinates && geoJson.coordinates.length >= 2) {
-      // Return coordinates as-is (already in [lng, lat] format)
-      return geoJson.coordinates;
+      // Return geometry as-is (coordinates already in [lng, lat] format)
+      return geoJson;
+    }
+    
+    // Routes with several tracks or segments keep one line per segment
+    if (geoJson.type === 'MultiLineString' && geoJson.coordinates && geoJson.coordinates.some(line => line.length >= 2)) {
+      return geoJson;
     }
     
     return null;
@@ -61,16 +66,17 @@ export const convertRoutesToMarkerData = (routes) => {
 export const convertRoutesToPathData = (routes) => {
   return routes
     .map((route) => {
-      const coordinates = parseSimplifiedPath(route.simplified_path);
+      const geometry = parseSimplifiedPath(route.simplified_path);
       
-      if (!coordinates) {
+      if (!geometry) {
         console.warn(`Route ${route.id} has invalid simplified_path, skipping path`);
         return null;
       }
       
       return {
         routeId: route.id,
-        coordinates: coordinates,
+        coordinates: geometry.type === 'MultiLineString' ? geometry.coordinates.flat() : geometry.coordinates,
+        geometry: geometry,
         routeName: route.name
       };
     })
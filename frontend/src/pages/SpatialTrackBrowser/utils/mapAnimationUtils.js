@@ -79,6 +79,23 @@ export const calculateBoundsFromCoordinates = (coordinates) => {
   }
 };
 
+/**
+ * Get all coordinates of a simplified path geometry
+ * @param {Object} simplifiedPath - Parsed LineString or MultiLineString (one line per track segment)
+ * @returns {Array|null} - Array of [lng, lat] coordinate pairs
+ */
+export const getSimplifiedPathCoordinates = (simplifiedPath) => {
+  if (!simplifiedPath || !simplifiedPath.coordinates) return null;
+  
+  if (simplifiedPath.type === 'LineString') {
+    return simplifiedPath.coordinates;
+  }
+  if (simplifiedPath.type === 'MultiLineString') {
+    return simplifiedPath.coordinates.flat();
+  }
+  return null;
+};
+
 /**
  * Animate map view to fit a route's bounding box
  * @param {Object} mapInstance - Mapbox GL map instance
@@ -104,8 +121,9 @@ export const animateToRoute = (mapInstance, route, options = {}) => {
     // If no bounding box, try to use simplified path
     if (!bounds && route.simplified_path) {
       const simplifiedPath = JSON.parse(route.simplified_path);
-      if (simplifiedPath.type === 'LineString' && simplifiedPath.coordinates) {
-        bounds = calculateBoundsFromCoordinates(simplifiedPath.coordinates);
+      const coordinates = getSimplifiedPathCoordinates(simplifiedPath);
+      if (coordinates) {
+        bounds = calculateBoundsFromCoordinates(coordinates);
       }
     }
     
@@ -197,8 +215,9 @@ export const animateToFitAllRoutes = (mapInstance, routes, options = {}) => {
       if (!bounds && route.simplified_path) {
         try {
           const simplifiedPath = JSON.parse(route.simplified_path);
-          if (simplifiedPath.type === 'LineString' && simplifiedPath.coordinates) {
-            bounds = calculateBoundsFromCoordinates(simplifiedPath.coordinates);
+          const coordinates = getSimplifiedPathCoordinates(simplifiedPath);
+          if (coordinates) {
+            bounds = calculateBoundsFromCoordinates(coordinates);
           }
         } catch (e) {
           // Skip this route
@@ -278,8 +297,9 @@ export const getOptimalZoomForRoute = (route, mapContainer) => {
     
     if (!bounds && route.simplified_path) {
       const simplifiedPath = JSON.parse(route.simplified_path);
-      if (simplifiedPath.type === 'LineString' && simplifiedPath.coordinates) {
-        bounds = calculateBoundsFromCoordinates(simplifiedPath.coordinates);
+      const coordinates = getSimplifiedPathCoordinates(simplifiedPath);
+      if (coordinates) {
+        bounds = calculateBoundsFromCoordinates(coordinates);
       }
     }
     